@@ -0,0 +1,356 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午7:40:00
+// @Desc appmsg（49 类型消息）子类型解码
+package wcf_rpc_sdk
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"github.com/Clov614/wcf-rpc-sdk/appmsg"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNotAppMsg = errors.New("not an appmsg")
+	ErrNotLink   = errors.New("not a link message")
+)
+
+// AppMsgType appmsg 子类型 <appmsg><type>
+type AppMsgType = appmsg.Type
+
+const (
+	AppMsgTypeText           = appmsg.TypeText           // 文本
+	AppMsgTypeImage          = appmsg.TypeImage          // 图片
+	AppMsgTypeMusic          = appmsg.TypeMusic          // 音乐
+	AppMsgTypeVideo          = appmsg.TypeVideo          // 视频
+	AppMsgTypeLink           = appmsg.TypeLink           // 链接、公众号文章
+	AppMsgTypeFile           = appmsg.TypeFile           // 文件
+	AppMsgTypeEmoji          = appmsg.TypeEmoji          // 表情
+	AppMsgTypeForward        = appmsg.TypeForward        // 合并转发的聊天记录
+	AppMsgTypeMiniProgram    = appmsg.TypeMiniProgram    // 小程序
+	AppMsgTypeMiniProgramApp = appmsg.TypeMiniProgramApp // 小程序 <带 url 的分享>
+	AppMsgTypeChannelVideo   = appmsg.TypeChannelVideo   // 视频号视频
+	AppMsgTypeQuote          = appmsg.TypeQuote          // 引用消息
+	AppMsgTypePat            = appmsg.TypePat            // 拍一拍
+	AppMsgTypeFileUploading  = appmsg.TypeFileUploading  // 文件上传中
+	AppMsgTypeMusicNew       = appmsg.TypeMusicNew       // 音乐 <新版>
+	AppMsgTypeAnnouncement   = appmsg.TypeAnnouncement   // 群公告
+	AppMsgTypeTransfer       = appmsg.TypeTransfer       // 转账
+	AppMsgTypeRedPacket      = appmsg.TypeRedPacket      // 红包
+)
+
+// AppMsg appmsg 的公共字段
+type AppMsg struct {
+	Type     AppMsgType  `xml:"type" json:"type"`
+	AppId    string      `xml:"appid,attr" json:"appId,omitempty"`
+	Title    string      `xml:"title" json:"title,omitempty"`
+	Des      string      `xml:"des" json:"des,omitempty"`
+	URL      string      `xml:"url" json:"url,omitempty"`
+	ThumbURL string      `xml:"thumburl" json:"thumbUrl,omitempty"`
+	Payload  interface{} `xml:"-" json:"payload,omitempty"` // 子类型解码器解析出的内容，如 *ChannelVideo
+}
+
+// AppMsgDecoder appmsg 子类型解码器 <content 为完整的 xml，返回消息类型与解析出的内容>
+type AppMsgDecoder func(content string) (MsgType, interface{}, error)
+
+var (
+	appMsgDecoders = map[AppMsgType]AppMsgDecoder{
+		AppMsgTypeImage:          appMsgHeader(MsgTypeXMLImage),
+		AppMsgTypeMusic:          decodeMusicCard,
+		AppMsgTypeVideo:          appMsgHeader(MsgTypeXMLVideo),
+		AppMsgTypeLink:           decodeLinkMsg,
+		AppMsgTypeFile:           decodeFileMsg,
+		AppMsgTypeForward:        decodeForwardMsg,
+		AppMsgTypeMiniProgram:    decodeMiniProgram,
+		AppMsgTypeMiniProgramApp: decodeMiniProgram,
+		AppMsgTypeChannelVideo:   decodeChannelVideo,
+		AppMsgTypeQuote:          decodeQuoteMsg,
+		AppMsgTypePat:            decodePatMsg,
+		AppMsgTypeFileUploading:  decodeFileMsg,
+		AppMsgTypeMusicNew:       decodeMusicCard,
+		AppMsgTypeAnnouncement:   decodeAnnouncement,
+		AppMsgTypeTransfer:       decodeTransfer,
+		AppMsgTypeRedPacket:      decodeRedPacket,
+	}
+	appMsgMu sync.RWMutex
+)
+
+// RegisterAppMsgDecoder 注册 appmsg 子类型解码器 <可覆盖内置的解码器，decoder 为 nil 时移除>
+func RegisterAppMsgDecoder(t AppMsgType, decoder AppMsgDecoder) {
+	appMsgMu.Lock()
+	defer appMsgMu.Unlock()
+	if decoder == nil {
+		delete(appMsgDecoders, t)
+		return
+	}
+	appMsgDecoders[t] = decoder
+}
+
+func appMsgDecoder(t AppMsgType) (AppMsgDecoder, bool) {
+	appMsgMu.RLock()
+	defer appMsgMu.RUnlock()
+	decoder, ok := appMsgDecoders[t]
+	return decoder, ok
+}
+
+// appMsgHeader 只需要公共字段的子类型
+func appMsgHeader(t MsgType) AppMsgDecoder {
+	return func(string) (MsgType, interface{}, error) {
+		return t, nil, nil
+	}
+}
+
+// trimAppMsg 去掉群聊中可能带有的 "roomid:\n" 前缀
+func trimAppMsg(content string) string {
+	if idx := strings.Index(content, "<"); idx > 0 {
+		return content[idx:]
+	}
+	return content
+}
+
+// parseAppMsg 解析 appmsg 的公共字段
+func parseAppMsg(content string) (*AppMsg, error) {
+	var msg struct {
+		AppMsg *AppMsg `xml:"appmsg"`
+	}
+	if err := xml.Unmarshal([]byte(trimAppMsg(content)), &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal appmsg err: %w", err)
+	}
+	if msg.AppMsg == nil {
+		return nil, ErrNotAppMsg
+	}
+	return msg.AppMsg, nil
+}
+
+// SendAppMsg 发送 appmsg 卡片 <wxid or roomid> <由 appmsg.Builder 构建的文档，未设置发送者时使用自己的 wxid>
+func (c *Client) SendAppMsg(receiver string, msg *appmsg.Msg) (*SendResult, error) {
+	return c.Outbox(SendPriorityNormal).AppMsg(receiver, msg).Wait()
+}
+
+// AppMsg 排队发送 appmsg <参数同 Client.SendAppMsg>
+func (o Outbox) AppMsg(receiver string, msg *appmsg.Msg) *SendFuture {
+	c := o.cli
+	if msg == nil {
+		return failedSend(fmt.Errorf("%w: nil msg", appmsg.ErrInvalid))
+	}
+	if msg.FromUserName == "" {
+		self, _ := c.GetSelfInfo()
+		doc := *msg
+		doc.FromUserName = self.Wxid
+		msg = &doc
+	}
+	content, err := msg.Marshal()
+	if err != nil {
+		return failedSend(err)
+	}
+	return c.enqueue(o.priority, receiver, func() (*SendResult, error) {
+		sent := c.newSendResult(receiver, MsgTypeXML)
+		res, err := c.wxClient.SendXmlCtx(c.ctx, "", content, receiver, xmlTypeAppMsg)
+		if err != nil {
+			logging.Debug("wxClient.SendXml", map[string]interface{}{"res": res, "receiver": receiver, "type": msg.AppMsg.Type})
+			return nil, fmt.Errorf("wxClient.SendXml err: %w", err)
+		}
+		return sent, nil
+	})
+}
+
+// fillAppMsg 按 <appmsg><type> 分发给对应的解码器 <未注册的子类型保持 MsgTypeXML>
+func (c *Client) fillAppMsg(m *Message) {
+	content := trimAppMsg(m.Content)
+	app, err := parseAppMsg(content)
+	if err != nil {
+		logging.Debug("parseAppMsg", map[string]interface{}{"err": err, "xml": content})
+		return
+	}
+	m.AppMsg = app
+	decoder, ok := appMsgDecoder(app.Type)
+	if !ok {
+		return
+	}
+	typ, payload, err := decoder(content)
+	if err != nil {
+		logging.Debug("decode appmsg", map[string]interface{}{"err": err, "type": app.Type, "xml": content})
+		return
+	}
+	m.Type = typ
+	app.Payload = payload
+	switch p := payload.(type) { // 兼容原有的字段
+	case *QuoteMsg:
+		m.Quote = p
+		m.Content = app.Title
+	case *ForwardMsg:
+		m.Forward = p
+	case *LinkMsg:
+		m.Link = p
+	case *MiniProgram:
+		m.MiniProgram = p
+	case *MusicCard:
+		m.Music = p
+	case *FileMsg:
+		m.Content = p.Title
+	case *Transfer:
+		m.Transfer = p
+	case *PatEvent:
+		c.setPatEvent(m, p)
+	}
+}
+
+func decodeQuoteMsg(content string) (MsgType, interface{}, error) {
+	referMsg, _, err := parseReferMsg(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	if referMsg == nil {
+		return 0, nil, errors.New("refermsg not found")
+	}
+	return MsgTypeXMLQuote, &referMsg.Quote, nil
+}
+
+func decodeForwardMsg(content string) (MsgType, interface{}, error) {
+	forwardMsg, err := parseForwardMsg(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return MsgTypeXMLForward, forwardMsg, nil
+}
+
+func decodeFileMsg(content string) (MsgType, interface{}, error) {
+	var msg struct { // FileMsg 的 FileExt 与 AppAttach 路径重叠，encoding/xml 无法直接解析，逐个字段读取
+		Title     string `xml:"appmsg>title"`
+		AppAttach struct {
+			TotalLen string `xml:"totallen"`
+			FileExt  string `xml:"fileext"`
+		} `xml:"appmsg>appattach"`
+	}
+	if err := xml.Unmarshal([]byte(content), &msg); err != nil {
+		return 0, nil, fmt.Errorf("unmarshal fileMsg err: %w", err)
+	}
+	file := &FileMsg{Title: msg.Title, FileExt: msg.AppAttach.FileExt}
+	file.AppAttach.TotalLen = msg.AppAttach.TotalLen
+	return MsgTypeXMLFile, file, nil
+}
+
+func decodeTransfer(content string) (MsgType, interface{}, error) {
+	transfer, err := parseTransfer(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return MsgTypeXMLTransfer, transfer, nil
+}
+
+func decodePatMsg(content string) (MsgType, interface{}, error) {
+	event, err := parsePatEvent(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return MsgTypePat, event, nil
+}
+
+// LinkMsg 链接消息 <appmsg type 5，公众号文章带有 SourceUsername>
+type LinkMsg struct {
+	Title             string `xml:"title" json:"title"`
+	Des               string `xml:"des" json:"des,omitempty"`
+	URL               string `xml:"url" json:"url"`
+	ThumbURL          string `xml:"thumburl" json:"thumbUrl,omitempty"`
+	SourceUsername    string `xml:"sourceusername" json:"sourceUsername,omitempty"`       // 来源公众号 gh_ id
+	SourceDisplayName string `xml:"sourcedisplayname" json:"sourceDisplayName,omitempty"` // 来源公众号名称
+}
+
+// parseLinkMsg 解析链接消息
+func parseLinkMsg(content string) (*LinkMsg, error) {
+	var msg struct {
+		AppMsg struct {
+			Type AppMsgType `xml:"type"`
+			LinkMsg
+		} `xml:"appmsg"`
+	}
+	if err := xml.Unmarshal([]byte(trimAppMsg(content)), &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal link err: %w", err)
+	}
+	if msg.AppMsg.Type != AppMsgTypeLink {
+		return nil, ErrNotLink
+	}
+	link := &msg.AppMsg.LinkMsg
+	link.Title = strings.TrimSpace(link.Title)
+	link.Des = strings.TrimSpace(link.Des)
+	link.URL = strings.TrimSpace(link.URL)
+	return link, nil
+}
+
+func decodeLinkMsg(content string) (MsgType, interface{}, error) {
+	link, err := parseLinkMsg(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return MsgTypeXMLLink, link, nil
+}
+
+// ChannelVideo 视频号视频 <appmsg type 51 的 finderFeed>
+type ChannelVideo struct {
+	ObjectId      string              `xml:"objectId" json:"objectId"`
+	ObjectNonceId string              `xml:"objectNonceId" json:"objectNonceId"`
+	FeedType      int                 `xml:"feedType" json:"feedType"`
+	NickName      string              `xml:"nickname" json:"nickName"`
+	Avatar        string              `xml:"avatar" json:"avatar,omitempty"`
+	Desc          string              `xml:"desc" json:"desc,omitempty"`
+	Media         []ChannelVideoMedia `xml:"mediaList>media" json:"media,omitempty"`
+}
+
+// ChannelVideoMedia 视频号视频中的媒体
+type ChannelVideoMedia struct {
+	MediaType int    `xml:"mediaType" json:"mediaType"`
+	URL       string `xml:"url" json:"url"`
+	ThumbURL  string `xml:"thumbUrl" json:"thumbUrl,omitempty"`
+	Duration  int    `xml:"videoPlayDuration" json:"duration,omitempty"` // 秒
+}
+
+func decodeChannelVideo(content string) (MsgType, interface{}, error) {
+	var msg struct {
+		Feed *ChannelVideo `xml:"appmsg>finderFeed"`
+	}
+	if err := xml.Unmarshal([]byte(content), &msg); err != nil {
+		return 0, nil, fmt.Errorf("unmarshal finderFeed err: %w", err)
+	}
+	if msg.Feed == nil {
+		return 0, nil, errors.New("finderFeed not found")
+	}
+	return MsgTypeXMLChannelVideo, msg.Feed, nil
+}
+
+// Announcement 群公告 <appmsg type 87>
+type Announcement struct {
+	Text string `xml:"appmsg>textannouncement" json:"text"`
+}
+
+func decodeAnnouncement(content string) (MsgType, interface{}, error) {
+	a := &Announcement{}
+	if err := xml.Unmarshal([]byte(content), a); err != nil {
+		return 0, nil, fmt.Errorf("unmarshal announcement err: %w", err)
+	}
+	return MsgTypeXMLAnnouncement, a, nil
+}
+
+// RedPacket 红包 <appmsg type 2001 的 wcpayinfo，红包需要在手机上领取>
+type RedPacket struct {
+	Wish      string `xml:"receivertitle" json:"wish"`            // 祝福语
+	SceneText string `xml:"scenetext" json:"sceneText,omitempty"` // 如 "微信红包"
+	NativeURL string `xml:"nativeurl" json:"nativeUrl,omitempty"`
+	PayMsgId  string `xml:"paymsgid" json:"payMsgId,omitempty"`
+}
+
+func decodeRedPacket(content string) (MsgType, interface{}, error) {
+	var msg struct {
+		RedPacket *RedPacket `xml:"appmsg>wcpayinfo"`
+	}
+	if err := xml.Unmarshal([]byte(content), &msg); err != nil {
+		return 0, nil, fmt.Errorf("unmarshal red packet err: %w", err)
+	}
+	if msg.RedPacket == nil {
+		return 0, nil, errors.New("wcpayinfo not found")
+	}
+	return MsgTypeXMLRedPacket, msg.RedPacket, nil
+}
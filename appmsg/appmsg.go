@@ -0,0 +1,169 @@
+// Package appmsg
+// @Author Clover
+// @Data 2026/10/18 下午9:50:00
+// @Desc appmsg（<msg><appmsg>…</appmsg></msg>）文档的构建与校验
+package appmsg
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrInvalid = errors.New("invalid appmsg")
+
+// Type appmsg 子类型 <appmsg><type>
+type Type int
+
+const (
+	TypeText           Type = 1    // 文本
+	TypeImage          Type = 2    // 图片
+	TypeMusic          Type = 3    // 音乐
+	TypeVideo          Type = 4    // 视频
+	TypeLink           Type = 5    // 链接、公众号文章
+	TypeFile           Type = 6    // 文件
+	TypeEmoji          Type = 8    // 表情
+	TypeForward        Type = 19   // 合并转发的聊天记录
+	TypeMiniProgram    Type = 33   // 小程序
+	TypeMiniProgramApp Type = 36   // 小程序 <带 url 的分享>
+	TypeChannelVideo   Type = 51   // 视频号视频
+	TypeQuote          Type = 57   // 引用消息
+	TypePat            Type = 62   // 拍一拍
+	TypeFileUploading  Type = 74   // 文件上传中
+	TypeMusicNew       Type = 76   // 音乐 <新版>
+	TypeAnnouncement   Type = 87   // 群公告
+	TypeTransfer       Type = 2000 // 转账
+	TypeRedPacket      Type = 2001 // 红包
+)
+
+func (t Type) String() string {
+	return strconv.Itoa(int(t))
+}
+
+// Msg appmsg 文档
+type Msg struct {
+	XMLName      xml.Name `xml:"msg"`
+	AppMsg       AppMsg   `xml:"appmsg"`
+	FromUserName string   `xml:"fromusername"` // 发送者 wxid <Client.SendAppMsg 会自动填充>
+	Scene        int      `xml:"scene"`
+	AppInfo      AppInfo  `xml:"appinfo"`
+	CommentURL   string   `xml:"commenturl"`
+}
+
+// AppMsg <appmsg> 节点
+type AppMsg struct {
+	AppId             string     `xml:"appid,attr"`
+	SdkVer            string     `xml:"sdkver,attr"`
+	Title             string     `xml:"title"`
+	Des               string     `xml:"des"`
+	Action            string     `xml:"action,omitempty"`
+	Type              Type       `xml:"type"`
+	URL               string     `xml:"url"`
+	LowURL            string     `xml:"lowurl,omitempty"`
+	DataURL           string     `xml:"dataurl,omitempty"`
+	LowDataURL        string     `xml:"lowdataurl,omitempty"`
+	ThumbURL          string     `xml:"thumburl,omitempty"`
+	SourceUserName    string     `xml:"sourceusername,omitempty"`
+	SourceDisplayName string     `xml:"sourcedisplayname,omitempty"`
+	AppAttach         *AppAttach `xml:"appattach,omitempty"`
+	ReferMsg          *ReferMsg  `xml:"refermsg,omitempty"`
+	WeAppInfo         *WeAppInfo `xml:"weappinfo,omitempty"`
+}
+
+// AppAttach 附件信息
+type AppAttach struct {
+	TotalLen       int64  `xml:"totallen"`
+	AttachId       string `xml:"attachid,omitempty"`
+	FileExt        string `xml:"fileext,omitempty"`
+	CdnThumbURL    string `xml:"cdnthumburl,omitempty"`
+	CdnThumbAesKey string `xml:"cdnthumbaeskey,omitempty"`
+	AesKey         string `xml:"aeskey,omitempty"`
+}
+
+// ReferMsg 被引用的消息
+type ReferMsg struct {
+	Type        int    `xml:"type"`
+	SvrId       string `xml:"svrid"`
+	FromUsr     string `xml:"fromusr"` // 会话 <群聊为 roomid>
+	ChatUsr     string `xml:"chatusr"` // 原消息的发送者
+	DisplayName string `xml:"displayname"`
+	Content     string `xml:"content"` // 原消息内容 <xml 内容同样会被转义>
+	CreateTime  int64  `xml:"createtime"`
+	MsgSource   string `xml:"msgsource"`
+}
+
+// WeAppInfo 小程序信息
+type WeAppInfo struct {
+	UserName       string `xml:"username"` // 小程序原始 id，如 gh_xxx@app
+	AppId          string `xml:"appid"`
+	Type           int    `xml:"type"` // 2 为小程序
+	Version        int    `xml:"version"`
+	IconURL        string `xml:"weappiconurl"`
+	PagePath       string `xml:"pagepath"`
+	AppServiceType int    `xml:"appservicetype"`
+}
+
+// AppInfo 来源应用
+type AppInfo struct {
+	Version int    `xml:"version"`
+	AppName string `xml:"appname"`
+}
+
+// Validate 按 appmsg 子类型检查必填字段
+func (m *Msg) Validate() error {
+	app := &m.AppMsg
+	missing := func(field string) error {
+		return fmt.Errorf("%w: type %s requires %s", ErrInvalid, app.Type, field)
+	}
+	if app.Type <= 0 {
+		return fmt.Errorf("%w: type is required", ErrInvalid)
+	}
+	if app.Title == "" {
+		return missing("title")
+	}
+	switch app.Type {
+	case TypeLink:
+		if app.URL == "" {
+			return missing("url")
+		}
+	case TypeMusic, TypeMusicNew:
+		if app.DataURL == "" && app.URL == "" {
+			return missing("dataurl or url")
+		}
+	case TypeFile:
+		if app.AppAttach == nil || app.AppAttach.FileExt == "" {
+			return missing("appattach.fileext")
+		}
+	case TypeMiniProgram, TypeMiniProgramApp:
+		if app.WeAppInfo == nil || app.WeAppInfo.AppId == "" || app.WeAppInfo.UserName == "" {
+			return missing("weappinfo.appid and weappinfo.username")
+		}
+	case TypeQuote:
+		if app.ReferMsg == nil || app.ReferMsg.SvrId == "" || app.ReferMsg.FromUsr == "" {
+			return missing("refermsg.svrid and refermsg.fromusr")
+		}
+	}
+	return nil
+}
+
+// Marshal 校验并生成 xml <字段由 encoding/xml 转义，xml 中不允许的字符会被替换为 U+FFFD>
+func (m *Msg) Marshal() (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	content, err := xml.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("marshal appmsg err: %w", err)
+	}
+	return xml.Header + string(content), nil
+}
+
+// Parse 解析 appmsg 文档
+func Parse(content string) (*Msg, error) {
+	m := &Msg{}
+	if err := xml.Unmarshal([]byte(content), m); err != nil {
+		return nil, fmt.Errorf("unmarshal appmsg err: %w", err)
+	}
+	return m, nil
+}
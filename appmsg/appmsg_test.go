@@ -0,0 +1,84 @@
+package appmsg
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMsg_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		wantErr bool
+	}{
+		{name: "No type", builder: New(0).Title("标题"), wantErr: true},
+		{name: "No title", builder: New(TypeLink).URL("https://example.com"), wantErr: true},
+		{name: "Link", builder: New(TypeLink).Title("标题").URL("https://example.com")},
+		{name: "Link without url", builder: New(TypeLink).Title("标题"), wantErr: true},
+		{name: "Music", builder: New(TypeMusic).Title("晴天").DataURL("https://example.com/a.mp3")},
+		{name: "Music without data", builder: New(TypeMusic).Title("晴天"), wantErr: true},
+		{name: "File", builder: New(TypeFile).Title("a.pdf").AppAttach(AppAttach{TotalLen: 10, FileExt: "pdf"})},
+		{name: "File without attach", builder: New(TypeFile).Title("a.pdf"), wantErr: true},
+		{name: "Mini program", builder: New(TypeMiniProgram).Title("菜单").WeAppInfo(WeAppInfo{AppId: "wx1234567890abcdef", UserName: "gh_0a1b2c3d4e5f@app"})},
+		{name: "Mini program without username", builder: New(TypeMiniProgram).Title("菜单").WeAppInfo(WeAppInfo{AppId: "wx1234567890abcdef"}), wantErr: true},
+		{name: "Quote", builder: New(TypeQuote).Title("回复").ReferMsg(ReferMsg{Type: 1, SvrId: "1", FromUsr: "wxid_a"})},
+		{name: "Quote without refermsg", builder: New(TypeQuote).Title("回复"), wantErr: true},
+		{name: "Unknown type", builder: New(9001).Title("自定义")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.builder.Build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Build() error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestBuilder_Escape(t *testing.T) {
+	title := `<b>"周五" & 'AT&T'</b>]]>`
+	refer := ReferMsg{Type: 49, SvrId: "1", FromUsr: "45959390469@chatroom", ChatUsr: "wxid_a",
+		Content: `<msg><appmsg><title>原消息 & 附件</title><type>6</type></appmsg></msg>`}
+	content, err := New(TypeQuote).Title(title).ReferMsg(refer).From("wxid_self").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if !strings.HasPrefix(content, "<?xml") || strings.Contains(content, "<b>") || strings.Contains(content, "<title>原消息") {
+		t.Errorf("Build() did not escape fields: %s", content)
+	}
+	msg, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if msg.AppMsg.Title != title || !reflect.DeepEqual(*msg.AppMsg.ReferMsg, refer) || msg.FromUserName != "wxid_self" {
+		t.Errorf("Parse() = %+v, refermsg %+v", msg.AppMsg, msg.AppMsg.ReferMsg)
+	}
+
+	// xml 中不允许的控制字符会被替换，避免生成非法文档
+	content, err = New(TypeLink).Title("a\x01b").URL("https://example.com/?a=1&b=2").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if msg, err = Parse(content); err != nil || msg.AppMsg.Title != "a�b" || msg.AppMsg.URL != "https://example.com/?a=1&b=2" {
+		t.Errorf("Parse() = %+v, %v", msg, err)
+	}
+}
+
+func TestBuilder_MsgCopy(t *testing.T) {
+	b := New(TypeFile).Title("a.pdf").AppAttach(AppAttach{TotalLen: 10, FileExt: "pdf"}).
+		ReferMsg(ReferMsg{SvrId: "1"}).WeAppInfo(WeAppInfo{AppId: "wx1"})
+	first := b.Msg()
+	first.AppMsg.AppAttach.FileExt = "doc"
+	first.AppMsg.ReferMsg.SvrId = "2"
+	first.AppMsg.WeAppInfo.AppId = "wx2"
+	second := b.Msg()
+	if second.AppMsg.AppAttach.FileExt != "pdf" || second.AppMsg.ReferMsg.SvrId != "1" || second.AppMsg.WeAppInfo.AppId != "wx1" {
+		t.Errorf("Msg() shares pointers with the builder: %+v %+v %+v", second.AppMsg.AppAttach, second.AppMsg.ReferMsg, second.AppMsg.WeAppInfo)
+	}
+}
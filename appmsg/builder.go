@@ -0,0 +1,125 @@
+// Package appmsg
+// @Author Clover
+// @Data 2026/10/18 下午9:50:00
+// @Desc appmsg 构建器
+package appmsg
+
+// Builder appmsg 构建器
+//
+//	content, err := appmsg.New(appmsg.TypeLink).Title("标题").Des("描述").URL("https://example.com").Build()
+type Builder struct {
+	msg Msg
+}
+
+// New 创建指定子类型的构建器
+func New(t Type) *Builder {
+	b := &Builder{}
+	b.msg.AppMsg.Type = t
+	b.msg.AppMsg.SdkVer = "0"
+	b.msg.AppInfo.Version = 1
+	return b
+}
+
+// AppId 来源应用的 appid
+func (b *Builder) AppId(appId string) *Builder {
+	b.msg.AppMsg.AppId = appId
+	return b
+}
+
+// Title 标题
+func (b *Builder) Title(title string) *Builder {
+	b.msg.AppMsg.Title = title
+	return b
+}
+
+// Des 描述
+func (b *Builder) Des(des string) *Builder {
+	b.msg.AppMsg.Des = des
+	return b
+}
+
+// Action <如 view>
+func (b *Builder) Action(action string) *Builder {
+	b.msg.AppMsg.Action = action
+	return b
+}
+
+// URL 点击卡片打开的地址
+func (b *Builder) URL(url string) *Builder {
+	b.msg.AppMsg.URL = url
+	return b
+}
+
+// DataURL 音频等数据地址 <同时作为 lowdataurl>
+func (b *Builder) DataURL(url string) *Builder {
+	b.msg.AppMsg.DataURL = url
+	b.msg.AppMsg.LowDataURL = url
+	return b
+}
+
+// ThumbURL 封面
+func (b *Builder) ThumbURL(url string) *Builder {
+	b.msg.AppMsg.ThumbURL = url
+	return b
+}
+
+// Source 来源公众号或小程序 <原始 id> <名称>
+func (b *Builder) Source(userName string, displayName string) *Builder {
+	b.msg.AppMsg.SourceUserName = userName
+	b.msg.AppMsg.SourceDisplayName = displayName
+	return b
+}
+
+// AppAttach 附件信息
+func (b *Builder) AppAttach(attach AppAttach) *Builder {
+	b.msg.AppMsg.AppAttach = &attach
+	return b
+}
+
+// ReferMsg 被引用的消息
+func (b *Builder) ReferMsg(refer ReferMsg) *Builder {
+	b.msg.AppMsg.ReferMsg = &refer
+	return b
+}
+
+// WeAppInfo 小程序信息
+func (b *Builder) WeAppInfo(info WeAppInfo) *Builder {
+	b.msg.AppMsg.WeAppInfo = &info
+	return b
+}
+
+// From 发送者 wxid
+func (b *Builder) From(wxid string) *Builder {
+	b.msg.FromUserName = wxid
+	return b
+}
+
+// AppInfo 来源应用
+func (b *Builder) AppInfo(info AppInfo) *Builder {
+	b.msg.AppInfo = info
+	return b
+}
+
+// Msg 构建出的文档 <未校验> <每次返回独立的副本>
+func (b *Builder) Msg() *Msg {
+	msg := b.msg
+	app := &msg.AppMsg
+	if app.AppAttach != nil { // 指针字段复制一份，避免与构建器及之前返回的 Msg 共用
+		attach := *app.AppAttach
+		app.AppAttach = &attach
+	}
+	if app.ReferMsg != nil {
+		refer := *app.ReferMsg
+		app.ReferMsg = &refer
+	}
+	if app.WeAppInfo != nil {
+		info := *app.WeAppInfo
+		app.WeAppInfo = &info
+	}
+	return &msg
+}
+
+// Build 校验并生成 xml
+func (b *Builder) Build() (string, error) {
+	return b.Msg().Marshal()
+}
@@ -7,18 +7,30 @@ package wcf_rpc_sdk
 import (
 	"github.com/Clov614/logging"
 	"sync"
+	"time"
 )
 
+// 群成员列表的缓存时长 <成员变动事件会立即清除对应群的缓存>
+const roomMembersTTL = 10 * time.Minute
+
 // ContactInfoManager 缓存管理器
 type ContactInfoManager struct {
 	contactInfoCache map[string]*ContactInfo
 	ciMu             sync.RWMutex
+	roomMembersCache map[string]roomMembers // 群成员列表 <roomid>
+	rmMu             sync.RWMutex
+}
+
+type roomMembers struct {
+	members []*ContactInfo
+	at      time.Time
 }
 
 // NewCacheInfoManager 创建缓存管理器
 func NewCacheInfoManager() *ContactInfoManager {
 	return &ContactInfoManager{
 		contactInfoCache: make(map[string]*ContactInfo),
+		roomMembersCache: make(map[string]roomMembers),
 	}
 }
 
@@ -39,6 +51,31 @@ func (cm *ContactInfoManager) GetContactInfo(id string) (*ContactInfo, bool) {
 	return c, ok
 }
 
+// CacheRoomMembers 缓存群成员列表
+func (cm *ContactInfoManager) CacheRoomMembers(roomId string, members []*ContactInfo) {
+	cm.rmMu.Lock()
+	defer cm.rmMu.Unlock()
+	cm.roomMembersCache[roomId] = roomMembers{members: members, at: time.Now()}
+}
+
+// GetRoomMembers 获取缓存的群成员列表 <过期视为未缓存>
+func (cm *ContactInfoManager) GetRoomMembers(roomId string) ([]*ContactInfo, bool) {
+	cm.rmMu.RLock()
+	defer cm.rmMu.RUnlock()
+	rm, ok := cm.roomMembersCache[roomId]
+	if !ok || time.Since(rm.at) > roomMembersTTL {
+		return nil, false
+	}
+	return rm.members, true
+}
+
+// InvalidateRoom 清除群成员列表缓存
+func (cm *ContactInfoManager) InvalidateRoom(roomId string) {
+	cm.rmMu.Lock()
+	defer cm.rmMu.Unlock()
+	delete(cm.roomMembersCache, roomId)
+}
+
 // Close 清理缓存
 func (cm *ContactInfoManager) Close() {
 	// todo
@@ -13,12 +13,13 @@ import (
 	"github.com/rs/zerolog"
 	"google.golang.org/protobuf/proto"
 	"html"
-	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,8 +31,17 @@ const (
 var (
 	ErrNotLogin = errors.New("not login")
 	ErrNull     = errors.New("null err")
+
+	ErrContactNotFound = errors.New("contact not found") // 联系人不存在
+
+	ErrTimeout = wcf.ErrTimeout // 接口调用超时
+	ErrClosed  = wcf.ErrClosed  // 与 wcf 的连接已关闭
+	ErrDecode  = wcf.ErrDecode  // 应答无法解析
 )
 
+// StatusError 接口执行失败（返回了表示失败的状态码）
+type StatusError = wcf.StatusError
+
 type Client struct {
 	ctx         context.Context
 	stop        context.CancelFunc
@@ -41,13 +51,24 @@ type Client struct {
 	self        *Self
 	cacheMember *ContactInfoManager // 用户信息缓存 fixme: 更改命名
 	closeOnce   sync.Once
-	memberLock  sync.Mutex // 查询member操作互斥锁
+	memberLock  sync.Mutex      // 查询member操作互斥锁
+	sv          *connSupervisor // 连接监控
+	msgStore    MessageStore    // 消息留存 <可选>
+	storeMu     sync.RWMutex
+	mediaLimit  atomic.Int64 // 媒体落盘的大小上限 <0 为 DefaultMaxMediaSize>
+	sq          *sendQueue   // 发送队列
+	textLimit   atomic.Int64 // SendLongText 单条文本的最大长度 <0 为 DefaultMaxTextLen>
+	events      chan Event   // 事件管道
+	eventsOn    atomic.Bool  // 是否已调用 Events
+	eventsMu    sync.RWMutex // 保护事件管道的关闭
+	evClosed    bool         // 事件管道是否已关闭
 }
 
 // Close 停止客户端
 func (c *Client) Close() {
 	c.closeOnce.Do(func() {
 		c.stop()
+		c.closeEvents()
 		if c.cacheMember != nil {
 			c.cacheMember.Close() // 释放信息缓存
 		}
@@ -94,7 +115,7 @@ func newClient(ctx context.Context, cancel context.CancelFunc, msgChanSize int,
 		logging.Fatal(fmt.Errorf("new wcf err: %w", err).Error(), 1001)
 		//panic(err)
 	}
-	return &Client{
+	c := &Client{
 		ctx:         ctx,
 		stop:        cancel,
 		msgBuffer:   NewMessageBuffer(msgChanSize), // 消息缓冲区 <缓冲大小>
@@ -102,7 +123,12 @@ func newClient(ctx context.Context, cancel context.CancelFunc, msgChanSize int,
 		self:        NewSelf(wxclient),
 		addr:        addr,
 		cacheMember: NewCacheInfoManager(),
+		sv:          newConnSupervisor(),
+		sq:          newSendQueue(),
+		events:      make(chan Event, msgChanSize),
 	}
+	go c.runSendQueue()
+	return c
 }
 
 // Run 运行tcp监听 以及 请求tcp监听信息 <是否debug>
@@ -134,143 +160,47 @@ func (c *Client) GetMsgChan() <-chan *Message {
 	return c.msgBuffer.msgCH
 }
 
-// SendText 发送普通文本 <wxid or roomid> <文本内容> <艾特的人(wxid) 所有人:(notify@all)> todo test 重构后待测试
-func (c *Client) SendText(receiver string, content string, ats ...string) error {
-	// 根据 wxid 获取对应的 Name
-	names := make([]string, 0, len(ats))
-	atList := make([]string, 0, len(ats))
-	for _, wxid := range ats {
-		if wxid == "notify@all" {
-			names = append(names, "所有人")
-			atList = append(atList, "notify@all")
-			continue
-		}
-		m := c.GetMember(wxid, true)
-		if m.NickName == "" && m.Alias == "" {
-			logging.Debug("sendText NickName && Alias null", map[string]interface{}{"wxid": wxid, "info": m})
-			names = append(names, wxid) // 如果获取失败，使用 wxid 代替
-			atList = append(atList, wxid)
-		} else {
-			if m.Alias != "" {
-				names = append(names, m.Alias)
-			} else if m.NickName != "" {
-				names = append(names, m.NickName)
-			}
-			atList = append(atList, wxid)
-		}
-	}
-
-	hasAt := strings.Contains(content, "@")
-
-	// 如果内容中不包含 @ 符号，则在开头添加 @<Name>
-	if !hasAt {
-		for _, name := range names {
-			content = "@" + name + " " + content
-		}
-	} else {
-		// 替换 @ 符号
-		for _, name := range names {
-			content = strings.Replace(content, "@", "@"+name+" ", 1)
-		}
-	}
+// SendText 发送普通文本 <wxid or roomid> <文本内容> <艾特的人(wxid) 所有人:(notify@all)>
+// 内容中的 {at:wxid} 会被替换为 @群昵称，ats 中未出现在占位符里的成员会被添加到开头
+func (c *Client) SendText(receiver string, content string, ats ...string) (*SendResult, error) {
+	return c.Outbox(SendPriorityNormal).Text(receiver, content, ats...).Wait()
+}
 
-	// 发送文本
-	res := c.wxClient.SendTxt(content, receiver, atList)
-	if res != 0 {
-		logging.Debug("wxCliend.SendTxt", map[string]interface{}{"res": res, "receiver": receiver, "content": content, "ats": ats})
-		return fmt.Errorf("wxClient.SendTxt err, code: %d", res)
+// Text 排队发送普通文本 <参数同 Client.SendText>
+func (o Outbox) Text(receiver string, content string, ats ...string) *SendFuture {
+	content, atList, _, err := o.cli.renderMentions(receiver, content, ats)
+	if err != nil {
+		return failedSend(err)
 	}
-	return nil
+	return o.text(receiver, content, atList)
 }
 
-// SendImage 发送图片 <wxid or roomid> <图片绝对路径>
-func (c *Client) SendImage(receiver string, src string) error {
-	var tmpFile *os.File    //  声明 tmpFile 变量
-	if imgutil.IsURL(src) { // 网络地址
-		bytes, err := imgutil.ImgFetch(src)
+func (o Outbox) text(receiver string, content string, atList []string) *SendFuture {
+	c := o.cli
+	return c.enqueue(o.priority, receiver, func() (*SendResult, error) {
+		sent := c.newTextSendResult(receiver, content)
+		res, err := c.wxClient.SendTxtCtx(c.ctx, content, receiver, atList)
 		if err != nil {
-			logging.ErrorWithErr(err, "imgutil.ImgFetch")
-			return err
+			logging.Debug("wxCliend.SendTxt", map[string]interface{}{"res": res, "receiver": receiver, "content": content, "ats": atList})
+			return nil, fmt.Errorf("wxClient.SendTxt err: %w", err)
 		}
-		// 创建临时文件
-		tmpFile, err = imgutil.CreateTempFile(".jpg")
-		if err != nil {
-			logging.ErrorWithErr(err, "imgutil.CreateTempFile")
-			return err
-		}
-		defer func() { // 使用闭包处理 tmpFile.Close() 的错误
-			if closeErr := tmpFile.Close(); closeErr != nil {
-				logging.ErrorWithErr(closeErr, "tmpFile.Close error in defer")
-			}
-		}()
-
-		// 写入临时文件
-		_, err = tmpFile.Write(bytes)
-		if err != nil {
-			logging.ErrorWithErr(err, "tmpFile.Write")
-			return err
-		}
-		src = tmpFile.Name() // 使用临时文件路径
-	}
-	res := c.wxClient.SendIMG(src, receiver)
-	if imgutil.IsURL(src) && tmpFile != nil { //  只有网络图片才删除临时文件, 并且确保 tmpFile 不为 nil
-		if removeErr := imgutil.RemoveTempFile(tmpFile.Name()); removeErr != nil {
-			logging.ErrorWithErr(removeErr, "imgutil.RemoveTempFile error")
-		}
-	}
-	if res != 0 {
-		logging.Debug("wxCliend.SendIMG", map[string]interface{}{"res": res, "receiver": receiver, "src": src}) // 打印 src 方便debug
-		return fmt.Errorf("wxClient.SendIMG err, code: %d", res)
-	}
-	return nil
+		return sent, nil
+	})
 }
 
-// SendImageBytes 发送图片字节数据 <wxid or roomid> <图片字节>
-func (c *Client) SendImageBytes(receiver string, imgBytes []byte) error {
-	// 创建临时文件
-	tmpFile, err := imgutil.CreateTempFile(".jpg") // 假设图片格式为 jpg，如果需要支持其他格式，可以调整
-	if err != nil {
-		logging.ErrorWithErr(err, "imgutil.CreateTempFile for SendImageBytes")
-		return err
-	}
-	defer func() {
-		// 关闭文件
-		if closeErr := tmpFile.Close(); closeErr != nil {
-			logging.ErrorWithErr(closeErr, "tmpFile.Close error in SendImageBytes defer")
-		}
-		// 删除临时文件
-		if removeErr := imgutil.RemoveTempFile(tmpFile.Name()); removeErr != nil {
-			logging.ErrorWithErr(removeErr, "imgutil.RemoveTempFile error in SendImageBytes defer")
-		}
-	}()
-
-	// 写入临时文件
-	_, err = tmpFile.Write(imgBytes)
-	if err != nil {
-		logging.ErrorWithErr(err, "tmpFile.Write for SendImageBytes")
-		return err
-	}
-
-	// 获取临时文件路径
-	src := tmpFile.Name()
+// SendImage 发送图片 <wxid or roomid> <图片绝对路径或网络地址>
+func (c *Client) SendImage(receiver string, src string) (*SendResult, error) {
+	return c.SendImageMedia(receiver, MediaFrom(src))
+}
 
-	// 发送图片
-	res := c.wxClient.SendIMG(src, receiver)
-	if res != 0 {
-		logging.Debug("wxCliend.SendIMG from SendImageBytes", map[string]interface{}{"res": res, "receiver": receiver, "src_len": len(imgBytes)}) // 打印字节长度方便debug
-		return fmt.Errorf("wxClient.SendIMG from SendImageBytes err, code: %d", res)
-	}
-	return nil
+// SendImageBytes 发送图片字节数据 <wxid or roomid> <图片字节> <扩展名按内容识别>
+func (c *Client) SendImageBytes(receiver string, imgBytes []byte) (*SendResult, error) {
+	return c.SendImageMedia(receiver, MediaBytes(imgBytes, ""))
 }
 
-// SendFile 发送图片 <wxid or roomid> <文件绝对路径> todo 支持网络地址发送文件
-func (c *Client) SendFile(receiver string, src string) error {
-	res := c.wxClient.SendFile(src, receiver)
-	if res != 0 {
-		logging.Debug("wxCliend.SendFile", map[string]interface{}{"res": res, "receiver": receiver})
-		return fmt.Errorf("wxClient.SendFile err, code: %d", res)
-	}
-	return nil
+// SendFile 发送文件 <wxid or roomid> <文件绝对路径或网络地址>
+func (c *Client) SendFile(receiver string, src string) (*SendResult, error) {
+	return c.SendFileMedia(receiver, MediaFrom(src))
 }
 
 // CardMessage 卡片消息结构体
@@ -284,13 +214,47 @@ type CardMessage struct {
 }
 
 // SendCardMessage 发送卡片消息
-func (c *Client) SendCardMessage(receiver string, card CardMessage) error {
-	res := c.wxClient.SendRichText(card.Name, card.Account, card.Title, card.Digest, card.URL, card.ThumbURL, receiver)
-	if res != 1 {
-		logging.Debug("wxClient.SendRichText", map[string]interface{}{"res": res, "receiver": receiver, "card": card})
-		return fmt.Errorf("wxClient.SendRichText err, code: %d", res)
+func (c *Client) SendCardMessage(receiver string, card CardMessage) (*SendResult, error) {
+	return c.enqueue(SendPriorityNormal, receiver, func() (*SendResult, error) {
+		sent := c.newSendResult(receiver, MsgTypeXML)
+		res, err := c.wxClient.SendRichTextCtx(c.ctx, card.Name, card.Account, card.Title, card.Digest, card.URL, card.ThumbURL, receiver)
+		if err != nil {
+			logging.Debug("wxClient.SendRichText", map[string]interface{}{"res": res, "receiver": receiver, "card": card})
+			return nil, fmt.Errorf("wxClient.SendRichText err: %w", err)
+		}
+		return sent, nil
+	}).Wait()
+}
+
+// SendContactCard 发送名片 <wxid or roomid> <名片对应的 wxid（好友或公众号）>
+func (c *Client) SendContactCard(receiver string, wxid string) (*SendResult, error) {
+	info, err := c.GetMemberCtx(c.ctx, wxid, false) // 不走缓存，名片需要联系人本身的微信号
+	if err != nil {
+		return nil, fmt.Errorf("get contact card info err: %w", err)
 	}
-	return nil
+	if info.Wxid == "" {
+		return nil, fmt.Errorf("%w: %s", ErrContactNotFound, wxid)
+	}
+	card := ContactCard{
+		UserName:     info.Wxid,
+		NickName:     info.NickName,
+		Alias:        info.Alias,
+		BigHeadURL:   info.BigHeadURL,
+		SmallHeadURL: info.SmallHeadURL,
+	}
+	content, err := xml.Marshal(card)
+	if err != nil {
+		return nil, fmt.Errorf("marshal contact card err: %w", err)
+	}
+	return c.enqueue(SendPriorityNormal, receiver, func() (*SendResult, error) {
+		sent := c.newSendResult(receiver, MsgTypeBusinessCard)
+		res, err := c.wxClient.SendXmlCtx(c.ctx, "", xml.Header+string(content), receiver, int32(MsgTypeBusinessCard))
+		if err != nil {
+			logging.Debug("wxClient.SendXml", map[string]interface{}{"res": res, "receiver": receiver, "wxid": wxid})
+			return nil, fmt.Errorf("wxClient.SendXml err: %w", err)
+		}
+		return sent, nil
+	}).Wait()
 }
 
 // AcceptNewFriend 通过好友请求
@@ -327,7 +291,48 @@ func (c *Client) CtGHs() ([]GH, error) {
 
 // RoomMembers 获取群成员信息
 func (c *Client) RoomMembers(roomId string) ([]*ContactInfo, error) {
-	contacts := c.wxClient.ExecDBQuery("MicroMsg.db", "SELECT RoomData FROM ChatRoom WHERE ChatRoomName = '"+roomId+"';")
+	return c.RoomMembersCtx(c.ctx, roomId)
+}
+
+// RoomMembersCtx 获取群成员信息 <优先走缓存，成员变动时缓存会被清除> <查询失败时返回 ErrTimeout / ErrClosed 等错误>
+func (c *Client) RoomMembersCtx(ctx context.Context, roomId string) ([]*ContactInfo, error) {
+	if members, ok := c.cacheMember.GetRoomMembers(roomId); ok {
+		return members, nil
+	}
+	roomMembers, err := c.queryRoomMembers(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	c.cacheMember.CacheRoomMembers(roomId, roomMembers)
+	return roomMembers, nil
+}
+
+// queryRoomMembers 从数据库查询群成员 <不读写群成员缓存>
+func (c *Client) queryRoomMembers(ctx context.Context, roomId string) ([]*ContactInfo, error) {
+	roomData, err := c.queryRoomData(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	var roomMembers = make([]*ContactInfo, len(roomData.GetMembers()))
+	for i, member := range roomData.GetMembers() {
+		info, err := c.GetMemberCtx(ctx, member.Wxid, true)
+		if err != nil {
+			return nil, fmt.Errorf("get member %s err: %w", member.Wxid, err)
+		}
+		cp := *info // info 为联系人缓存中的共享数据，群昵称只写入副本
+		cp.Wxid = member.Wxid
+		cp.Alias = member.Name
+		roomMembers[i] = &cp
+	}
+	return roomMembers, nil
+}
+
+// queryRoomData 查询群的成员列表 <只含 wxid 与群昵称，单次数据库查询>
+func (c *Client) queryRoomData(ctx context.Context, roomId string) (*wcf.RoomData, error) {
+	contacts, err := c.wxClient.ExecDBQueryCtx(ctx, "MicroMsg.db", "SELECT RoomData FROM ChatRoom WHERE ChatRoomName = '"+roomId+"';")
+	if err != nil {
+		return nil, fmt.Errorf("query room data err: %w", err)
+	}
 	logging.Debug("GetRoomMemberID", map[string]interface{}{"roomId": roomId, "contacts": contacts})
 
 	if len(contacts) == 0 || len(contacts[0].GetFields()) == 0 {
@@ -338,34 +343,34 @@ func (c *Client) RoomMembers(roomId string) ([]*ContactInfo, error) {
 
 	roomData := &wcf.RoomData{}
 
-	err := proto.Unmarshal(roomDataBytes, roomData)
+	err = proto.Unmarshal(roomDataBytes, roomData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal RoomData: %w", err)
 	}
-	var roomMembers = make([]*ContactInfo, len(roomData.GetMembers()))
-	for i, member := range roomData.GetMembers() {
-		roomMembers[i] = c.GetMember(member.Wxid, true)
-		roomMembers[i].Wxid = member.Wxid
-		roomMembers[i].Alias = member.Name
-	}
-
-	return roomMembers, nil
+	return roomData, nil
 }
 
 // ChatRoomOwner 获取群主
 func (c *Client) ChatRoomOwner(roomId string) *ContactInfo {
-	res := c.wxClient.ExecDBQuery("MicroMsg.db", "SELECT Reserved2 FROM ChatRoom WHERE ChatRoomName = '"+roomId+"';")
-	if res == nil || len(res) == 0 || len(res[0].GetFields()) == 0 {
-		logging.Debug("获取群组错误", map[string]interface{}{"roomId": roomId, "res": res})
+	info, err := c.ChatRoomOwnerCtx(c.ctx, roomId)
+	if err != nil {
+		logging.Debug("获取群组错误", map[string]interface{}{"roomId": roomId, "err": err.Error()})
 		return nil
 	}
-	Reserved2 := res[0].GetFields()[0].Content
-	wxid := string(Reserved2)
-	info, ok := c.cacheMember.GetContactInfo(wxid)
-	if ok || info != nil {
-		return info // 返回群主信息
+	return info
+}
+
+// ChatRoomOwnerCtx 获取群主 <缓存中没有群主信息时查询数据库>
+func (c *Client) ChatRoomOwnerCtx(ctx context.Context, roomId string) (*ContactInfo, error) {
+	res, err := c.wxClient.ExecDBQueryCtx(ctx, "MicroMsg.db", "SELECT Reserved2 FROM ChatRoom WHERE ChatRoomName = '"+roomId+"';")
+	if err != nil {
+		return nil, fmt.Errorf("query room owner err: %w", err)
 	}
-	return nil
+	if len(res) == 0 || len(res[0].GetFields()) == 0 {
+		return nil, fmt.Errorf("no room data found for roomId: %s", roomId)
+	}
+	wxid := string(res[0].GetFields()[0].Content)
+	return c.GetMemberCtx(ctx, wxid, true)
 }
 
 // GetSelfInfo 获取账号个人信息
@@ -391,19 +396,35 @@ func (c *Client) GetSelfFileStoragePath() (string, bool) {
 	return info.FileStoragePath, ok
 }
 
+// GetMember 获取联系人信息 <byCache 优先走缓存> <查询失败时返回空结构，需要错误信息请使用 GetMemberCtx>
 func (c *Client) GetMember(id string, byCache bool) *ContactInfo {
+	info, err := c.GetMemberCtx(c.ctx, id, byCache)
+	if err != nil {
+		logging.ErrorWithErr(err, "client.GetMember", map[string]interface{}{"id": id})
+		return &ContactInfo{}
+	}
+	return info
+}
+
+// GetMemberCtx 获取联系人信息 <byCache 优先走缓存> <联系人不存在时返回空结构与 nil 错误>
+func (c *Client) GetMemberCtx(ctx context.Context, id string, byCache bool) (*ContactInfo, error) {
 	if byCache { // 走缓存
 		info, b := c.cacheMember.GetContactInfo(id)
 		if b {
-			return info
+			return info, nil
 		}
 	}
 	var cInfo = &ContactInfo{}
-	contacts := c.wxClient.ExecDBQuery("MicroMsg.db", fmt.Sprintf("select * from Contact where UserName = '%s';", id)) // 注意 原字段 UserName指的就是 wxid
+	contacts, err := c.wxClient.ExecDBQueryCtx(ctx, "MicroMsg.db", fmt.Sprintf("select * from Contact where UserName = '%s';", id)) // 注意 原字段 UserName指的就是 wxid
+	if err != nil {
+		return nil, fmt.Errorf("query contact err: %w", err)
+	}
 	if len(contacts) != 0 {
-		c.nomalize(contacts[0], cInfo)
+		if err = c.nomalize(ctx, contacts[0], cInfo); err != nil {
+			return nil, err
+		}
 	}
-	return cInfo
+	return cInfo, nil
 }
 
 // cyclicUpdateSelfInfo 定时更新机器人信息 <immediate 立即执行一次>
@@ -484,7 +505,11 @@ func (c *Client) getAllMember() *[]*ContactInfo {
 		return nil
 	}
 	defer c.memberLock.Unlock()
-	contacts := c.wxClient.ExecDBQuery("MicroMsg.db", "select * from Contact;")
+	contacts, err := c.wxClient.ExecDBQueryCtx(c.ctx, "MicroMsg.db", "select * from Contact;")
+	if err != nil {
+		logging.ErrorWithErr(err, "client.getAllMember: queryDB err")
+		return nil
+	}
 	if len(contacts) == 0 {
 		logging.Error("client.getAllMember: queryDB res is nil")
 		return nil
@@ -492,7 +517,10 @@ func (c *Client) getAllMember() *[]*ContactInfo {
 	var memberList = make([]*ContactInfo, 0, len(contacts))
 	for _, contact := range contacts {
 		var cInfo = &ContactInfo{}
-		c.nomalize(contact, cInfo)
+		if err := c.nomalize(c.ctx, contact, cInfo); err != nil {
+			logging.ErrorWithErr(err, "client.getAllMember: nomalize")
+			return nil
+		}
 		memberList = append(memberList, cInfo)
 	}
 	//logging.Debug("client.getAllMember()", map[string]interface{}{"memberList": memberList})
@@ -500,7 +528,7 @@ func (c *Client) getAllMember() *[]*ContactInfo {
 }
 
 // 解析 ContactInfo
-func (c *Client) nomalize(contact *wcf.DbRow, cInfo *ContactInfo) {
+func (c *Client) nomalize(ctx context.Context, contact *wcf.DbRow, cInfo *ContactInfo) error {
 	for _, field := range contact.Fields {
 		switch field.Column {
 		case "UserName":
@@ -540,7 +568,10 @@ func (c *Client) nomalize(contact *wcf.DbRow, cInfo *ContactInfo) {
 	}
 	// 查询小头像和大头像
 	if cInfo.Wxid != "" {
-		query := c.wxClient.ExecDBQuery("MicroMsg.db", fmt.Sprintf("select * from ContactHeadImgUrl where usrName = '%s';", cInfo.Wxid))
+		query, err := c.wxClient.ExecDBQueryCtx(ctx, "MicroMsg.db", fmt.Sprintf("select * from ContactHeadImgUrl where usrName = '%s';", cInfo.Wxid))
+		if err != nil {
+			return fmt.Errorf("query head img url err: %w", err)
+		}
 		for _, row := range query {
 			for _, field := range row.Fields {
 				switch field.Column {
@@ -553,6 +584,7 @@ func (c *Client) nomalize(contact *wcf.DbRow, cInfo *ContactInfo) {
 		}
 	}
 	c.cacheMember.CacheContactInfo(cInfo) // 更新缓存
+	return nil
 }
 
 // GetFullFilePathFromRelativePath 通过相对路径获取完整文件路径
@@ -584,20 +616,18 @@ func (c *Client) handleMsg(ctx context.Context) (err error) {
 		if covertedMsg == nil {
 			return ErrNull
 		}
-		err = c.msgBuffer.Put(c.ctx, covertedMsg) // 缓冲消息（内存中）
-		if err != nil {
+		if c.eventsOn.Load() { // 已改用事件管道，不再写入 GetMsgChan
+			if err := c.publish(newMessageEvent(covertedMsg)); err != nil {
+				return fmt.Errorf("MessageHandler err: %w", err)
+			}
+			return nil
+		}
+		if err := c.msgBuffer.Put(c.ctx, covertedMsg); err != nil { // 缓冲消息（内存中）
 			return fmt.Errorf("MessageHandler err: %w", err)
 		}
 		return nil
 	}
-	go func() {
-		//c.wxClient.DisableRecvTxt()          // 重置可能的状态
-		c.wxClient.EnableRecvTxt()           // 允许接收消息
-		err = c.wxClient.OnMSG(ctx, handler) // 当消息到来时，处理消息
-		if err != nil {
-			logging.ErrorWithErr(err, "handlerMsg err")
-		}
-	}()
+	go c.supervise(ctx, handler) // 接收消息，断线时自动重连并恢复接收
 	return nil
 }
 
@@ -646,40 +676,57 @@ func (c *Client) covertMsg(msg *wcf.WxMsg) *Message {
 	if m.Type == MsgTypeImage {
 		time.Sleep(50 * time.Microsecond)
 		c.wxClient.DownloadAttach(m.MessageId, m.Thumb, m.Extra) // 下载图片
-		m.FileInfo = &FileInfo{FilePath: filepath.ToSlash(m.Extra), IsImg: true}
+		m.FileInfo = &FileInfo{FilePath: filepath.ToSlash(m.Extra), IsImg: true, cli: c}
+	}
+
+	// 位置解析
+	if m.Type == MsgTypeLocation {
+		location, err := parseLocation(msg.Content)
+		if err != nil {
+			logging.Debug("parseLocation", map[string]interface{}{"err": err, "xml": msg.Content})
+		} else {
+			m.Location = location
+		}
+	}
+
+	// 名片解析
+	if m.Type == MsgTypeBusinessCard {
+		card, err := parseContactCard(msg.Content)
+		if err != nil {
+			logging.Debug("parseContactCard", map[string]interface{}{"err": err, "xml": msg.Content})
+		} else {
+			m.ContactCard = card
+		}
+	}
+
+	// 系统消息解析 <撤回、拍一拍、群成员变动>
+	if m.Type == MsgTypeRevoke {
+		switch sysmsgType(m.Content) {
+		case "revokemsg":
+			c.fillRevokeEvent(m)
+		case "pat":
+			c.fillPatEvent(m)
+		case "sysmsgtemplate":
+			c.fillMemberChange(m)
+		}
+	}
+	if m.Type == MsgTypeSystem {
+		c.fillMemberChange(m) // 群成员变动
+	}
+	if m.Type == MsgTypePat && m.PatEvent == nil {
+		c.fillPatEvent(m)
 	}
 
-	// 解析XML
+	// 解析XML <按 appmsg 子类型分发>
 	if msg.Type == uint32(MsgTypeXML) { // 49
-		if strings.Contains(msg.Content, "<refermsg>") {
-			referMsg, content, err := parseReferMsg(msg.Content)
-			if err != nil {
-				logging.Debug("parseReferMsg", map[string]interface{}{"err": err, "xml": msg.Xml})
-			} else {
-				m.Type = MsgTypeXMLQuote
-				m.Quote = &referMsg.Quote
-				m.Content = content
-			}
-		} else if strings.Contains(msg.Content, "<recorditem>") { // 新增的转发消息解析逻辑
-			forwardMsg, err := parseForwardMsg(msg.Content)
-			if err != nil {
-				logging.Debug("parseForwardMsg", map[string]interface{}{"err": err, "xml": msg.Xml})
-			} else {
-				m.Type = MsgTypeXMLForward // 假设您已经定义了这个新的消息类型
-				m.Forward = forwardMsg
-			}
+		c.fillAppMsg(m)
+	}
+	if m.Type == MsgTypeMusicLink {
+		music, err := parseMusicCard(msg.Content)
+		if err != nil {
+			logging.Debug("parseMusicCard", map[string]interface{}{"err": err, "xml": msg.Content})
 		} else {
-			// 检查是否是文件类型
-			fileMsg := &FileMsg{}
-			err := xml.Unmarshal([]byte(msg.Content), fileMsg)
-			if err != nil {
-				logging.Debug("xml.Unmarshal fileMsg", map[string]interface{}{"err": err, "xml": msg.Xml})
-			} else {
-				if fileMsg.FileExt != "" {
-					m.Type = MsgTypeXMLFile
-					m.Content = fileMsg.Title
-				}
-			}
+			m.Music = music
 		}
 	}
 
@@ -694,9 +741,23 @@ func (c *Client) covertMsg(msg *wcf.WxMsg) *Message {
 		self:   c.self,
 	}
 	m.meta = metaData
+	if store := c.messageStore(); store != nil && m.Type != MsgTypeRevoke {
+		store.Put(m) // 留存消息，用于撤回时找回原消息
+	}
 	return m
 }
 
+// 系统消息的类型 <sysmsg type="revokemsg">
+var sysmsgTypePattern = regexp.MustCompile(`<sysmsg\s+type="([^"]*)"`)
+
+func sysmsgType(content string) string {
+	match := sysmsgTypePattern.FindStringSubmatch(content)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
 func fillNewFriendReq(m *Message) {
 	if m.Content != "" { // 确保 Content 不为空
 		doc, err := xmlquery.Parse(strings.NewReader(m.Content))
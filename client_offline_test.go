@@ -0,0 +1,1277 @@
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/appmsg"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcftest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testSelfWxid = "wxid_wcftest_self"
+	testRoomId   = "45959390469@chatroom"
+	testFriendA  = "wxid_pagpb98c6nj722"
+	testFriendB  = "wxid_jj4mhsji9tjk22"
+)
+
+// newOfflineClient 启动伪 wcf 服务端并以 autoInject=false 连接
+func newOfflineClient(t *testing.T) (*Client, *wcftest.Server) {
+	t.Helper()
+	srv, err := wcftest.NewServer("")
+	if err != nil {
+		t.Fatalf("wcftest.NewServer() error = %v", err)
+	}
+	srv.AddContact(wcftest.Contact{Wxid: testFriendA, NickName: "Alice"})
+	srv.AddContact(wcftest.Contact{Wxid: testFriendB, NickName: "Bob"})
+	srv.AddContact(wcftest.Contact{Wxid: testSelfWxid, NickName: "wcftest"})
+	srv.AddChatRoom(testRoomId, "测试12", testFriendA,
+		wcftest.RoomMember{Wxid: testFriendA, Name: "Alice"},
+		wcftest.RoomMember{Wxid: testFriendB, Name: "Bob"},
+		wcftest.RoomMember{Wxid: testSelfWxid, Name: "wcftest"},
+	)
+	t.Setenv(ENVTcpAddr, srv.Addr())
+	cli := NewClient(10, false, false)
+	t.Cleanup(func() {
+		cli.Close()
+		_ = srv.Close()
+	})
+	return cli, srv
+}
+
+// recvMsg 从消息通道中取一条消息
+func recvMsg(t *testing.T, cli *Client) *Message {
+	t.Helper()
+	select {
+	case msg := <-cli.GetMsgChan():
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for message")
+	}
+	return nil
+}
+
+func TestOfflineClient_SelfAndContacts(t *testing.T) {
+	cli, _ := newOfflineClient(t)
+
+	if !cli.IsLogin() {
+		t.Fatalf("IsLogin() = false, want true")
+	}
+	if wxid, ok := cli.GetSelfWxId(); !ok || wxid != testSelfWxid {
+		t.Errorf("GetSelfWxId() = %q, %v", wxid, ok)
+	}
+	friends, err := cli.CtFriends()
+	if err != nil || len(friends) != 3 {
+		t.Errorf("CtFriends() = %v, %v", friends, err)
+	}
+	rooms, err := cli.CtChatRooms()
+	if err != nil || len(rooms) != 1 || rooms[0].RoomID != testRoomId {
+		t.Errorf("CtChatRooms() = %v, %v", rooms, err)
+	}
+	if m := cli.GetMember(testFriendB, false); m.NickName != "Bob" {
+		t.Errorf("GetMember() = %#v", m)
+	}
+	members, err := cli.RoomMembers(testRoomId)
+	if err != nil || len(members) != 3 {
+		t.Fatalf("RoomMembers() = %v, %v", members, err)
+	}
+	if members[0].Wxid != testFriendA || members[0].NickName != "Alice" {
+		t.Errorf("RoomMembers()[0] = %#v", members[0])
+	}
+}
+
+func TestOfflineClient_RecvAndReply(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+
+	srv.Push(&wcf.WxMsg{Id: 10, Type: uint32(MsgTypeText), IsGroup: true, Roomid: testRoomId, Sender: testFriendA, Content: "@wcftest\u2005ping"})
+	msg := recvMsg(t, cli)
+	if msg.MessageId != 10 || msg.RoomId != testRoomId || msg.Content != "@wcftest\u2005ping" {
+		t.Fatalf("received %#v", msg)
+	}
+	if msg.RoomData == nil || !msg.RoomData.IsAtSelf {
+		t.Errorf("RoomData.IsAtSelf = false, want true")
+	}
+	if _, err := msg.ReplyText("pong"); err != nil {
+		t.Fatalf("ReplyText() error = %v", err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_TXT)
+	if req.GetTxt().GetReceiver() != testRoomId || req.GetTxt().GetMsg() != "pong" {
+		t.Errorf("SEND_TXT request = %v", req)
+	}
+
+	srv.SetStatus(wcf.Functions_FUNC_SEND_TXT, -1)
+	if _, err := cli.SendText(testFriendA, "hello"); err == nil {
+		t.Errorf("SendText() error = nil with status -1")
+	}
+}
+
+func TestOfflineClient_SurfaceErrors(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	srv.SetStatus(wcf.Functions_FUNC_SEND_TXT, -1)
+	var se *StatusError
+	if _, err := cli.SendText(testFriendA, "hello"); !errors.As(err, &se) || se.Status != -1 {
+		t.Errorf("SendText() error = %v, want StatusError -1", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cli.RoomMembersCtx(ctx, testRoomId); !errors.Is(err, context.Canceled) {
+		t.Errorf("RoomMembersCtx(canceled) error = %v, want context.Canceled", err)
+	}
+	if _, err := cli.GetMemberCtx(ctx, testFriendB, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetMemberCtx(canceled) error = %v, want context.Canceled", err)
+	}
+	if m, err := cli.GetMemberCtx(context.Background(), "wxid_nobody", false); err != nil || m.Wxid != "" {
+		t.Errorf("GetMemberCtx(nobody) = %#v, %v, want empty, nil", m, err)
+	}
+	if owner, err := cli.ChatRoomOwnerCtx(context.Background(), testRoomId); err != nil || owner.Wxid != testFriendA {
+		t.Errorf("ChatRoomOwnerCtx() = %#v, %v", owner, err)
+	}
+
+	_ = cli.wxClient.Close()
+	if _, err := cli.RoomMembersCtx(context.Background(), testRoomId); !errors.Is(err, ErrClosed) {
+		t.Errorf("RoomMembersCtx(closed) error = %v, want ErrClosed", err)
+	}
+}
+
+// waitState 等待状态管道中出现指定状态
+func waitState(t *testing.T, cli *Client, want ConnState) StateChange {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case change := <-cli.GetStateChan():
+			if change.State == want {
+				return change
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for state %s, current %s", want, cli.State())
+		}
+	}
+}
+
+func TestOfflineClient_Reconnect(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	cli.SetReconnectPolicy(ReconnectPolicy{
+		HealthInterval: 50 * time.Millisecond,
+		HealthTimeout:  100 * time.Millisecond,
+		MaxFailures:    1,
+		MinBackoff:     20 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+	})
+	var callbacks []ConnState
+	var mu sync.Mutex
+	cli.OnStateChange(func(change StateChange) {
+		mu.Lock()
+		callbacks = append(callbacks, change.State)
+		mu.Unlock()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	waitState(t, cli, StateConnected)
+
+	// 服务端重启：命令与消息 socket 均断开
+	addr := srv.Addr()
+	_ = srv.Close()
+	if change := waitState(t, cli, StateReconnecting); change.Err == nil {
+		t.Errorf("StateReconnecting without cause")
+	}
+	srv2, err := wcftest.NewServer(addr)
+	if err != nil {
+		t.Fatalf("wcftest.NewServer(%s) error = %v", addr, err)
+	}
+	defer srv2.Close()
+	waitState(t, cli, StateConnected)
+	if !srv2.RecvTxtEnabled() {
+		t.Errorf("EnableRecvTxt not re-issued after reconnect")
+	}
+
+	// 消息接收恢复
+	srv2.Push(&wcf.WxMsg{Id: 20, Type: uint32(MsgTypeText), Sender: testFriendA, Content: "back"})
+	if msg := recvMsg(t, cli); msg.MessageId != 20 || msg.Content != "back" {
+		t.Errorf("received %#v after reconnect", msg)
+	}
+
+	srv2.SetLogin(false)
+	waitState(t, cli, StateLoggedOut)
+
+	cancel()
+	waitState(t, cli, StateClosed)
+	mu.Lock()
+	defer mu.Unlock()
+	want := []ConnState{StateConnected, StateReconnecting, StateConnected, StateLoggedOut, StateClosed}
+	if !reflect.DeepEqual(callbacks, want) {
+		t.Errorf("state callbacks = %v, want %v", callbacks, want)
+	}
+}
+
+func TestOfflineClient_Revoke(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	sent, err := cli.SendText(testFriendA, "oops")
+	if err != nil {
+		t.Fatalf("SendText() error = %v", err)
+	}
+	if _, err = cli.SendImage(testFriendA, "C:/a.jpg"); err != nil { // 同一接收者的其他类型消息不影响查找
+		t.Fatalf("SendImage() error = %v", err)
+	}
+	id, err := sent.MsgId()
+	if err != nil || id != wcftest.FirstSentMsgId {
+		t.Fatalf("MsgId() = %d, %v, want %d", id, err, wcftest.FirstSentMsgId)
+	}
+	if err = sent.Revoke(); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if req := srv.LastRequest(wcf.Functions_FUNC_REVOKE_MSG); req.GetUi64() != id {
+		t.Errorf("REVOKE_MSG request = %v, want id %d", req, id)
+	}
+
+	srv.SetStatus(wcf.Functions_FUNC_REVOKE_MSG, 0) // 超过两分钟等情况
+	if err = cli.RevokeMsg(id); !wcf.IsStatusError(err) {
+		t.Errorf("RevokeMsg() error = %v, want StatusError", err)
+	}
+
+	// 同一秒内发出的多条文本按内容区分
+	first, _ := cli.SendText(testFriendB, "it's 1")
+	second, _ := cli.SendText(testFriendB, "it's 2")
+	id1, err1 := first.MsgId()
+	id2, err2 := second.MsgId()
+	if err1 != nil || err2 != nil || id1 == id2 {
+		t.Errorf("MsgId() = %d, %v and %d, %v, want distinct ids", id1, err1, id2, err2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	missing := cli.newSendResult(testRoomId, MsgTypeText)
+	if _, err = missing.MsgIdCtx(ctx); !errors.Is(err, ErrMsgNotFound) {
+		t.Errorf("MsgIdCtx() error = %v, want ErrMsgNotFound", err)
+	}
+
+	if err = (&Message{meta: &meta{cli: cli}, MessageId: 1}).Revoke(); !errors.Is(err, ErrNotSelfMsg) {
+		t.Errorf("Message.Revoke() error = %v, want ErrNotSelfMsg", err)
+	}
+}
+
+func TestOfflineClient_OCR(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	var calls atomic.Int32
+	srv.Handle(wcf.Functions_FUNC_EXEC_OCR, func(req *wcf.Request) *wcf.Response {
+		if calls.Add(1) < 3 { // 图片尚未下载完成
+			return &wcf.Response{Msg: &wcf.Response_Ocr{Ocr: &wcf.OcrMsg{Status: 1}}}
+		}
+		return &wcf.Response{Msg: &wcf.Response_Ocr{Ocr: &wcf.OcrMsg{Status: 0, Result: "合计 12.50\n" + req.GetStr()}}}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	extra := "C:/Users/wcftest/Documents/WeChat Files/wxid_wcftest_self/FileStorage/MsgAttach/a/Image/2026-10/b.dat"
+	srv.Push(&wcf.WxMsg{Id: 30, Type: uint32(MsgTypeImage), Sender: testFriendA, Extra: extra})
+	msg := recvMsg(t, cli)
+	if msg.FileInfo == nil {
+		t.Fatalf("FileInfo = nil for image message")
+	}
+	text, err := msg.FileInfo.OCR()
+	if err != nil || text != "合计 12.50\n"+extra || calls.Load() != 3 {
+		t.Errorf("FileInfo.OCR() = %q, %v after %d calls", text, err, calls.Load())
+	}
+
+	srv.Handle(wcf.Functions_FUNC_EXEC_OCR, func(req *wcf.Request) *wcf.Response {
+		return &wcf.Response{Msg: &wcf.Response_Ocr{Ocr: &wcf.OcrMsg{Status: -1}}}
+	})
+	tctx, tcancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
+	defer tcancel()
+	if _, err = cli.OCRCtx(tctx, extra); !errors.Is(err, ErrOCRNotReady) {
+		t.Errorf("OCRCtx() error = %v, want ErrOCRNotReady", err)
+	}
+	if _, err = (&FileInfo{}).OCR(); !errors.Is(err, ErrNoClient) {
+		t.Errorf("FileInfo{}.OCR() error = %v, want ErrNoClient", err)
+	}
+}
+
+func TestOfflineClient_ContactCard(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	sent, err := cli.SendContactCard(testRoomId, testFriendB)
+	if err != nil || sent.Type != MsgTypeBusinessCard {
+		t.Fatalf("SendContactCard() = %+v, %v", sent, err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_XML).GetXml()
+	if req.GetType() != int32(MsgTypeBusinessCard) || req.GetReceiver() != testRoomId {
+		t.Errorf("SendXml request = %+v", req)
+	}
+	card, err := parseContactCard(req.GetContent())
+	if err != nil || card.UserName != testFriendB || card.NickName != "Bob" {
+		t.Errorf("sent card = %+v, %v", card, err)
+	}
+	if id, err := sent.MsgId(); err != nil || id == 0 {
+		t.Errorf("MsgId() = %d, %v", id, err)
+	}
+	if _, err = cli.SendContactCard(testRoomId, "wxid_nobody"); !errors.Is(err, ErrContactNotFound) {
+		t.Errorf("SendContactCard(unknown) error = %v, want ErrContactNotFound", err)
+	}
+
+	// 收到的名片消息
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 50, Type: uint32(MsgTypeBusinessCard), Sender: testFriendA, Content: req.GetContent()})
+	msg := recvMsg(t, cli)
+	if msg.ContactCard == nil || msg.ContactCard.UserName != testFriendB {
+		t.Errorf("ContactCard = %+v", msg.ContactCard)
+	}
+}
+
+func TestOfflineClient_RevokeEvent(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	cli.SetMessageStore(NewMemoryMessageStore(0, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 7862452235853946623, Type: uint32(MsgTypeText), IsGroup: true, Roomid: testRoomId, Sender: testFriendA, Content: "秘密"})
+	original := recvMsg(t, cli)
+	srv.Push(&wcf.WxMsg{Id: 60, Type: uint32(MsgTypeRevoke), IsGroup: true, Roomid: testRoomId, Sender: testFriendA,
+		Content: `<sysmsg type="revokemsg"><revokemsg><session>45959390469@chatroom</session><msgid>1057020960</msgid><newmsgid>7862452235853946623</newmsgid><replacemsg><![CDATA["Alice" 撤回了一条消息]]></replacemsg></revokemsg></sysmsg>`})
+	msg := recvMsg(t, cli)
+	ev := msg.RevokeEvent
+	if ev == nil || ev.Revoker != testFriendA || ev.NewMsgId != original.MessageId || ev.Session != testRoomId {
+		t.Fatalf("RevokeEvent = %+v", ev)
+	}
+	if ev.Original != original || ev.Original.Content != "秘密" {
+		t.Errorf("RevokeEvent.Original = %+v, want the revoked message", ev.Original)
+	}
+}
+
+func TestOfflineClient_Pat(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 70, Type: uint32(MsgTypeRevoke), IsGroup: true, Roomid: testRoomId, Sender: testRoomId,
+		Content: `<sysmsg type="pat"><pat><fromusername>wxid_pagpb98c6nj722</fromusername><chatusername>45959390469@chatroom</chatusername><pattedusername>wxid_wcftest_self</pattedusername><template><![CDATA["${wxid_pagpb98c6nj722}" 拍了拍 "${wxid_wcftest_self}"]]></template></pat></sysmsg>`})
+	msg := recvMsg(t, cli)
+	ev := msg.PatEvent
+	if msg.Type != MsgTypePat || ev == nil || !ev.IsPatted(testSelfWxid) {
+		t.Fatalf("PatEvent = %+v, type %d", ev, msg.Type)
+	}
+	if ev.Template != `"Alice" 拍了拍 "wcftest"` {
+		t.Errorf("PatEvent.Template = %q", ev.Template)
+	}
+	if err := msg.PatBack(); err != nil {
+		t.Fatalf("PatBack() error = %v", err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_PAT_MSG).GetPm()
+	if req.GetRoomid() != testRoomId || req.GetWxid() != testFriendA {
+		t.Errorf("SendPat request = %+v", req)
+	}
+	srv.SetStatus(wcf.Functions_FUNC_SEND_PAT_MSG, 0)
+	var se *StatusError
+	if err := cli.SendPat(testRoomId, testFriendB); !errors.As(err, &se) {
+		t.Errorf("SendPat() error = %v, want StatusError", err)
+	}
+}
+
+func TestOfflineClient_MemberChange(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	if members, err := cli.RoomMembers(testRoomId); err != nil || len(members) != 3 {
+		t.Fatalf("RoomMembers() = %v, %v", members, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+
+	srv.AddContact(wcftest.Contact{Wxid: "wxid_carol", NickName: "Carol"})
+	srv.SetRoomMembers(testRoomId,
+		wcftest.RoomMember{Wxid: testFriendA, Name: "Alice"},
+		wcftest.RoomMember{Wxid: testFriendB, Name: "Bob"},
+		wcftest.RoomMember{Wxid: testSelfWxid, Name: "wcftest"},
+		wcftest.RoomMember{Wxid: "wxid_carol", Name: "Carol"},
+	)
+	if members, _ := cli.RoomMembers(testRoomId); len(members) != 3 {
+		t.Fatalf("RoomMembers() = %d members, want the cached 3", len(members))
+	}
+	srv.Push(&wcf.WxMsg{Id: 80, Type: uint32(MsgTypeSystem), IsGroup: true, Roomid: testRoomId, Sender: testRoomId, Content: `"Alice"邀请"Carol"加入了群聊`})
+	ev := recvMsg(t, cli).MemberChange
+	if ev == nil || ev.Kind != MemberJoined || ev.JoinMethod != JoinByInvite || ev.Actor.Wxid != testFriendA {
+		t.Fatalf("MemberChange = %+v", ev)
+	}
+	if !reflect.DeepEqual(ev.Wxids(), []string{"wxid_carol"}) {
+		t.Errorf("MemberChange.Wxids() = %v", ev.Wxids())
+	}
+	if members, _ := cli.RoomMembers(testRoomId); len(members) != 4 {
+		t.Errorf("RoomMembers() = %d members after join, want 4", len(members))
+	}
+
+	srv.SetRoomMembers(testRoomId,
+		wcftest.RoomMember{Wxid: testFriendA, Name: "Alice"},
+		wcftest.RoomMember{Wxid: testSelfWxid, Name: "wcftest"},
+		wcftest.RoomMember{Wxid: "wxid_carol", Name: "Carol"},
+	)
+	srv.Push(&wcf.WxMsg{Id: 81, Type: uint32(MsgTypeSystem), IsGroup: true, Roomid: testRoomId, Sender: testRoomId, Content: `你将"Bob"移出了群聊`})
+	ev = recvMsg(t, cli).MemberChange
+	if ev == nil || ev.Kind != MemberRemoved || ev.Actor.Wxid != testSelfWxid || !reflect.DeepEqual(ev.Wxids(), []string{testFriendB}) {
+		t.Fatalf("MemberChange = %+v", ev)
+	}
+	if members, _ := cli.RoomMembers(testRoomId); len(members) != 3 {
+		t.Errorf("RoomMembers() = %d members after kick, want 3", len(members))
+	}
+
+	// 数据库的成员列表晚于入群消息更新时，不应缓存旧列表
+	srv.AddContact(wcftest.Contact{Wxid: "wxid_dave", NickName: "Dave"})
+	srv.Push(&wcf.WxMsg{Id: 82, Type: uint32(MsgTypeSystem), IsGroup: true, Roomid: testRoomId, Sender: testRoomId, Content: `"Alice"邀请"Dave"加入了群聊`})
+	if ev = recvMsg(t, cli).MemberChange; ev == nil || ev.Kind != MemberJoined {
+		t.Fatalf("MemberChange = %+v", ev)
+	}
+	srv.SetRoomMembers(testRoomId,
+		wcftest.RoomMember{Wxid: testFriendA, Name: "Alice"},
+		wcftest.RoomMember{Wxid: testSelfWxid, Name: "wcftest"},
+		wcftest.RoomMember{Wxid: "wxid_carol", Name: "Carol"},
+		wcftest.RoomMember{Wxid: "wxid_dave", Name: "Dave"},
+	)
+	if members, _ := cli.RoomMembers(testRoomId); len(members) != 4 {
+		t.Errorf("RoomMembers() = %d members after delayed update, want 4", len(members))
+	}
+
+	// 入群时只查询新成员，不逐个查询已有成员
+	const bigRoom = "48201933158@chatroom"
+	members := []wcftest.RoomMember{{Wxid: testFriendA, Name: "Alice"}, {Wxid: testSelfWxid, Name: "wcftest"}}
+	for i := 0; i < 30; i++ {
+		members = append(members, wcftest.RoomMember{Wxid: "wxid_member" + strconv.Itoa(i)})
+	}
+	srv.AddChatRoom(bigRoom, "大群", testFriendA, members...)
+	if _, err := cli.RoomMembers(bigRoom); err != nil {
+		t.Fatalf("RoomMembers() error = %v", err)
+	}
+	srv.AddContact(wcftest.Contact{Wxid: "wxid_erin", NickName: "Erin"})
+	srv.SetRoomMembers(bigRoom, append(members, wcftest.RoomMember{Wxid: "wxid_erin"})...)
+	before := len(srv.Requests())
+	srv.Push(&wcf.WxMsg{Id: 83, Type: uint32(MsgTypeSystem), IsGroup: true, Roomid: bigRoom, Sender: bigRoom, Content: `"Alice"邀请"Erin"加入了群聊`})
+	if ev = recvMsg(t, cli).MemberChange; ev == nil || !reflect.DeepEqual(ev.Wxids(), []string{"wxid_erin"}) {
+		t.Fatalf("MemberChange = %+v", ev)
+	}
+	var queries int
+	for _, req := range srv.Requests()[before:] {
+		if req.GetFunc() == wcf.Functions_FUNC_EXEC_DB_QUERY {
+			queries++
+		}
+	}
+	if queries > 5 {
+		t.Errorf("join event sent %d db queries, want only the new member looked up", queries)
+	}
+}
+
+func TestOfflineClient_Rooms(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	rooms := cli.Rooms()
+
+	if err := rooms.Add(testRoomId, "wxid_carol"); err != nil {
+		t.Fatalf("Rooms.Add() error = %v", err)
+	}
+	if req := srv.LastRequest(wcf.Functions_FUNC_ADD_ROOM_MEMBERS).GetM(); req.GetRoomid() != testRoomId || req.GetWxids() != "wxid_carol" {
+		t.Errorf("AddChatRoomMembers request = %+v", req)
+	}
+	if err := rooms.Invite(testRoomId, "wxid_carol", "wxid_dave"); err != nil {
+		t.Errorf("Rooms.Invite() error = %v", err)
+	}
+	if err := rooms.Add(testRoomId); !errors.Is(err, ErrNoMembers) {
+		t.Errorf("Rooms.Add() without members error = %v, want ErrNoMembers", err)
+	}
+	if err := rooms.Remove("404@chatroom", testFriendB); !errors.Is(err, ErrNotInRoom) {
+		t.Errorf("Rooms.Remove() on unknown room error = %v, want ErrNotInRoom", err)
+	}
+	if req := srv.LastRequest(wcf.Functions_FUNC_DEL_ROOM_MEMBERS); req != nil {
+		t.Errorf("DelChatRoomMembers sent for a room the bot is not in: %+v", req)
+	}
+
+	srv.SetStatus(wcf.Functions_FUNC_DEL_ROOM_MEMBERS, 0)
+	err := rooms.Remove(testRoomId, testFriendB)
+	var opErr *RoomOpError
+	var se *StatusError
+	if !errors.Is(err, ErrRoomOpRejected) || !errors.As(err, &opErr) || opErr.Op != RoomOpRemove || !errors.As(err, &se) {
+		t.Errorf("Rooms.Remove() error = %v, want rejected RoomOpError", err)
+	}
+	srv.SetStatus(wcf.Functions_FUNC_INV_ROOM_MEMBERS, -1)
+	if err = rooms.Invite(testRoomId, "wxid_carol"); !errors.Is(err, ErrRoomOpFailed) {
+		t.Errorf("Rooms.Invite() error = %v, want ErrRoomOpFailed", err)
+	}
+
+	if owner, err := rooms.Owner(testRoomId); err != nil || owner.Wxid != testFriendA {
+		t.Errorf("Rooms.Owner() = %+v, %v", owner, err)
+	}
+	if members, err := rooms.Members(testRoomId); err != nil || len(members) != 3 {
+		t.Errorf("Rooms.Members() = %v, %v", members, err)
+	}
+	if _, err = rooms.Members("404@chatroom"); !errors.Is(err, ErrNotInRoom) {
+		t.Errorf("Rooms.Members() on unknown room error = %v, want ErrNotInRoom", err)
+	}
+}
+
+func TestOfflineClient_Transfer(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	const content = `<msg><appmsg appid="" sdkver=""><title><![CDATA[微信转账]]></title><type>2000</type><wcpayinfo><paysubtype>%d</paysubtype><feedesc><![CDATA[￥12.50]]></feedesc><transcationid><![CDATA[tx-1]]></transcationid><transferid><![CDATA[tf-1]]></transferid><pay_memo><![CDATA[]]></pay_memo><receiver_username><![CDATA[wxid_wcftest_self]]></receiver_username><payer_username><![CDATA[%s]]></payer_username></wcpayinfo></appmsg></msg>`
+	srv.Push(&wcf.WxMsg{Id: 100, Type: uint32(MsgTypeXML), Sender: testFriendA, Content: fmt.Sprintf(content, 1, testFriendA)})
+	msg := recvMsg(t, cli)
+	if msg.Type != MsgTypeXMLTransfer || msg.Transfer == nil || msg.Transfer.Amount != 12.5 {
+		t.Fatalf("Transfer = %+v, type %d", msg.Transfer, msg.Type)
+	}
+	if err := msg.AcceptTransfer(); err != nil {
+		t.Fatalf("AcceptTransfer() error = %v", err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_RECV_TRANSFER).GetTf()
+	if req.GetWxid() != testFriendA || req.GetTfid() != "tf-1" || req.GetTaid() != "tx-1" {
+		t.Errorf("ReceiveTransfer request = %+v", req)
+	}
+
+	srv.Push(&wcf.WxMsg{Id: 101, Type: uint32(MsgTypeXML), Sender: testFriendA, Content: fmt.Sprintf(content, 3, testFriendA)})
+	if err := recvMsg(t, cli).AcceptTransfer(); !errors.Is(err, ErrTransferNotPending) {
+		t.Errorf("AcceptTransfer() on received transfer error = %v", err)
+	}
+	srv.SetStatus(wcf.Functions_FUNC_RECV_TRANSFER, 0)
+	var se *StatusError
+	if err := cli.ReceiveTransfer(testFriendA, "tf-2", "tx-2"); !errors.As(err, &se) {
+		t.Errorf("ReceiveTransfer() error = %v, want StatusError", err)
+	}
+}
+
+func TestOfflineClient_AppMsgDecoder(t *testing.T) {
+	type vote struct{ Topic string }
+	const appMsgTypeVote AppMsgType = 9001
+	RegisterAppMsgDecoder(appMsgTypeVote, func(content string) (MsgType, interface{}, error) {
+		app, err := parseAppMsg(content)
+		if err != nil {
+			return 0, nil, err
+		}
+		return MsgTypeXML, &vote{Topic: app.Title}, nil
+	})
+	defer RegisterAppMsgDecoder(appMsgTypeVote, nil)
+
+	cli, srv := newOfflineClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 110, Type: uint32(MsgTypeXML), IsGroup: true, Roomid: testRoomId, Sender: testFriendA,
+		Content: `<msg><appmsg appid="" sdkver="0"><title>午饭吃什么</title><type>9001</type></appmsg></msg>`})
+	msg := recvMsg(t, cli)
+	if v, ok := msg.AppMsg.Payload.(*vote); !ok || v.Topic != "午饭吃什么" {
+		t.Fatalf("AppMsg = %+v", msg.AppMsg)
+	}
+
+	srv.Push(&wcf.WxMsg{Id: 111, Type: uint32(MsgTypeXML), Sender: testFriendA,
+		Content: `<msg><appmsg appid="" sdkver="0"><title>回复内容</title><type>57</type><refermsg><type>1</type><svrid>123456</svrid><fromusr>wxid_wcftest_self</fromusr><chatusr>wxid_wcftest_self</chatusr><displayname>wcftest</displayname><content>原消息</content></refermsg></appmsg></msg>`})
+	msg = recvMsg(t, cli)
+	if msg.Type != MsgTypeXMLQuote || msg.Quote == nil || msg.Quote.SvrId != "123456" || msg.Content != "回复内容" {
+		t.Errorf("quote message = %+v, quote %+v", msg, msg.Quote)
+	}
+}
+
+func TestOfflineClient_MiniProgram(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	mp := MiniProgram{Title: "今日菜单 <午餐> & 晚餐", AppId: "wx1234567890abcdef", UserName: "gh_0a1b2c3d4e5f@app",
+		PagePath: "pages/menu/index?day=5", DisplayName: "食堂助手", IconURL: "https://wx.qlogo.cn/icon.png"}
+	sent, err := cli.SendMiniProgram(testRoomId, mp)
+	if err != nil || sent.Type != MsgTypeXML {
+		t.Fatalf("SendMiniProgram() = %+v, %v", sent, err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_XML).GetXml()
+	if req.GetType() != xmlTypeAppMsg || req.GetReceiver() != testRoomId {
+		t.Errorf("SendXml request = %+v", req)
+	}
+	if id, err := sent.MsgId(); err != nil || id == 0 {
+		t.Errorf("MsgId() = %d, %v", id, err)
+	}
+	if _, err = cli.SendMiniProgram(testRoomId, MiniProgram{Title: "no appid"}); !errors.Is(err, ErrInvalidMiniProgram) {
+		t.Errorf("SendMiniProgram(invalid) error = %v, want ErrInvalidMiniProgram", err)
+	}
+
+	// 收到的小程序消息
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 120, Type: uint32(MsgTypeXML), IsGroup: true, Roomid: testRoomId, Sender: testFriendA, Content: req.GetContent()})
+	msg := recvMsg(t, cli)
+	want := mp
+	want.PagePath = "pages/menu/index.html?day=5"
+	if msg.Type != MsgTypeXMLMiniProgram || msg.MiniProgram == nil || *msg.MiniProgram != want {
+		t.Errorf("MiniProgram = %+v, type %d", msg.MiniProgram, msg.Type)
+	}
+}
+
+func TestOfflineClient_Quote(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 130, Type: uint32(MsgTypeText), Ts: 1729238400, IsGroup: true, Roomid: testRoomId, Sender: testFriendA, Content: "明天几点开会？"})
+	question := recvMsg(t, cli)
+
+	sent, err := question.ReplyQuote("九点 <三楼> & 带电脑")
+	if err != nil || sent.Receiver != testRoomId {
+		t.Fatalf("ReplyQuote() = %+v, %v", sent, err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_XML).GetXml()
+	if req.GetType() != xmlTypeAppMsg || req.GetReceiver() != testRoomId {
+		t.Errorf("SendXml request = %+v", req)
+	}
+	q, err := appmsg.Parse(req.GetContent())
+	if err != nil || q.AppMsg.ReferMsg == nil {
+		t.Fatalf("appmsg.Parse(sent quote) = %+v, %v", q, err)
+	}
+	want := appmsg.ReferMsg{Type: 1, SvrId: "130", FromUsr: testRoomId, ChatUsr: testFriendA, DisplayName: "Alice", Content: "明天几点开会？", CreateTime: 1729238400}
+	if *q.AppMsg.ReferMsg != want || q.FromUserName != testSelfWxid {
+		t.Errorf("sent refermsg = %+v, from %s", q.AppMsg.ReferMsg, q.FromUserName)
+	}
+	if id, err := sent.MsgId(); err != nil || id == 0 {
+		t.Errorf("MsgId() = %d, %v", id, err)
+	}
+	if _, err = cli.SendQuote(testRoomId, "", question); !errors.Is(err, ErrInvalidQuote) {
+		t.Errorf("SendQuote(empty) error = %v, want ErrInvalidQuote", err)
+	}
+
+	// 发出的引用消息可被解析
+	srv.Push(&wcf.WxMsg{Id: 131, Type: uint32(MsgTypeXML), IsSelf: true, IsGroup: true, Roomid: testRoomId, Sender: testSelfWxid, Content: req.GetContent()})
+	msg := recvMsg(t, cli)
+	if msg.Type != MsgTypeXMLQuote || msg.Content != "九点 <三楼> & 带电脑" || msg.Quote.SvrId != "130" || msg.Quote.ChatUser != testFriendA {
+		t.Errorf("quote message = %q, type %d, quote %+v", msg.Content, msg.Type, msg.Quote)
+	}
+}
+
+func TestOfflineClient_Music(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	music := MusicCard{Title: "晴天", Singer: "周杰伦", URL: "https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV",
+		DataURL: "https://music.example.com/qingtian.mp3?vkey=a&guid=b", ThumbURL: "https://y.gtimg.cn/music/photo_new/T002R300x300M000.jpg", AppID: "wx5aa333606550dfd5"}
+	sent, err := cli.SendMusic(testFriendA, music)
+	if err != nil || sent.Type != MsgTypeXML {
+		t.Fatalf("SendMusic() = %+v, %v", sent, err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_XML).GetXml()
+	if req.GetType() != xmlTypeAppMsg || req.GetReceiver() != testFriendA {
+		t.Errorf("SendXml request = %+v", req)
+	}
+	if id, err := sent.MsgId(); err != nil || id == 0 {
+		t.Errorf("MsgId() = %d, %v", id, err)
+	}
+	if _, err = cli.SendMusic(testFriendA, MusicCard{Title: "no data url"}); !errors.Is(err, ErrInvalidMusic) {
+		t.Errorf("SendMusic(invalid) error = %v, want ErrInvalidMusic", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	srv.Push(&wcf.WxMsg{Id: 140, Type: uint32(MsgTypeXML), Sender: testFriendA, Content: req.GetContent()})
+	msg := recvMsg(t, cli)
+	if msg.Type != MsgTypeXMLMusic || msg.Music == nil || *msg.Music != music {
+		t.Errorf("Music = %+v, type %d", msg.Music, msg.Type)
+	}
+	srv.Push(&wcf.WxMsg{Id: 141, Type: uint32(MsgTypeMusicLink), Sender: testFriendA, Content: req.GetContent()})
+	msg = recvMsg(t, cli)
+	if msg.Type != MsgTypeMusicLink || msg.Music == nil || *msg.Music != music {
+		t.Errorf("MusicLink = %+v, type %d", msg.Music, msg.Type)
+	}
+}
+
+func TestOfflineClient_SendAppMsg(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	doc := appmsg.New(appmsg.TypeLink).Title("周报 <第 42 周> & 计划").Des("点击查看").URL("https://example.com/report?week=42&team=bot").Msg()
+	sent, err := cli.SendAppMsg(testRoomId, doc)
+	if err != nil || sent.Type != MsgTypeXML {
+		t.Fatalf("SendAppMsg() = %+v, %v", sent, err)
+	}
+	if doc.FromUserName != "" {
+		t.Errorf("SendAppMsg() modified the caller's msg: from %s", doc.FromUserName)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_XML).GetXml()
+	if req.GetType() != xmlTypeAppMsg || req.GetReceiver() != testRoomId {
+		t.Errorf("SendXml request = %+v", req)
+	}
+	link, err := parseLinkMsg(req.GetContent())
+	if err != nil || link.Title != "周报 <第 42 周> & 计划" || link.URL != "https://example.com/report?week=42&team=bot" {
+		t.Errorf("sent link = %+v, %v", link, err)
+	}
+	if _, err = cli.SendAppMsg(testRoomId, appmsg.New(appmsg.TypeLink).Title("no url").Msg()); !errors.Is(err, appmsg.ErrInvalid) {
+		t.Errorf("SendAppMsg(invalid) error = %v, want appmsg.ErrInvalid", err)
+	}
+}
+
+func TestOfflineClient_Media(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp) // 临时文件写在这里，便于检查是否清理
+
+	png := append([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}, make([]byte, 64)...)
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(png)
+	}))
+	defer web.Close()
+
+	sentPath := func(fn wcf.Functions) string {
+		t.Helper()
+		p := srv.LastRequest(fn).GetFile().GetPath()
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("temp file %s was not removed: %v", p, err)
+		}
+		return p
+	}
+
+	// 网络图片的 url 没有扩展名，按内容识别为 png
+	if _, err := cli.SendImage(testFriendA, web.URL+"/img?id=1"); err != nil {
+		t.Fatalf("SendImage(url) error = %v", err)
+	}
+	if p := sentPath(wcf.Functions_FUNC_SEND_IMG); filepath.Ext(p) != ".png" {
+		t.Errorf("SEND_IMG path = %s, want .png", p)
+	}
+	// 文件名沿用 name
+	if _, err := cli.SendFileMedia(testFriendA, MediaReader(strings.NewReader("a,b\n1,2\n"), "报表.csv")); err != nil {
+		t.Fatalf("SendFileMedia(reader) error = %v", err)
+	}
+	if p := sentPath(wcf.Functions_FUNC_SEND_FILE); filepath.Base(p) != "报表.csv" {
+		t.Errorf("SEND_FILE path = %s, want 报表.csv", p)
+	}
+	// 视频无文件名时按内容识别为 mp4
+	mp4 := append([]byte{0, 0, 0, 0x20, 'f', 't', 'y', 'p', 'i', 's', 'o', 'm'}, make([]byte, 32)...)
+	sent, err := cli.SendVideo(testRoomId, MediaBytes(mp4, ""))
+	if err != nil || sent.Type != MsgTypeVideo {
+		t.Fatalf("SendVideo() = %+v, %v", sent, err)
+	}
+	if p := sentPath(wcf.Functions_FUNC_SEND_FILE); filepath.Ext(p) != ".mp4" {
+		t.Errorf("SEND_FILE path = %s, want .mp4", p)
+	}
+	if id, err := sent.MsgId(); err != nil || id == 0 {
+		t.Errorf("SendVideo().MsgId() = %d, %v", id, err)
+	}
+	// 本地路径原样交给 wcf
+	if _, err = cli.SendFile(testFriendA, "C:/docs/a.pdf"); err != nil {
+		t.Fatalf("SendFile(path) error = %v", err)
+	}
+	if p := srv.LastRequest(wcf.Functions_FUNC_SEND_FILE).GetFile().GetPath(); p != "C:/docs/a.pdf" {
+		t.Errorf("SEND_FILE path = %s, want C:/docs/a.pdf", p)
+	}
+
+	cli.SetMaxMediaSize(16)
+	if _, err = cli.SendImage(testFriendA, web.URL+"/big.png"); !errors.Is(err, ErrMediaTooLarge) {
+		t.Errorf("SendImage(too large url) error = %v, want ErrMediaTooLarge", err)
+	}
+	if _, err = cli.SendFileMedia(testFriendA, MediaReader(strings.NewReader(strings.Repeat("x", 17)), "a.txt")); !errors.Is(err, ErrMediaTooLarge) {
+		t.Errorf("SendFileMedia(too large reader) error = %v, want ErrMediaTooLarge", err)
+	}
+	if _, err = cli.SendImageBytes(testFriendA, nil); !errors.Is(err, ErrInvalidMedia) {
+		t.Errorf("SendImageBytes(nil) error = %v, want ErrInvalidMedia", err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("temp dir not cleaned: %v", entries)
+	}
+}
+
+func TestOfflineClient_SendQueue(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+
+	// 全局限速时优先级高的先发
+	cli.SetSendPolicy(SendPolicy{GlobalRate: 5, GlobalBurst: 1, ReceiverRate: -1, MaxJitter: -1})
+	if _, err := cli.SendText(testFriendA, "first"); err != nil {
+		t.Fatalf("SendText() error = %v", err)
+	}
+	low := cli.Outbox(SendPriorityLow).Text(testFriendB, "low")
+	high := cli.Outbox(SendPriorityHigh).Text(testFriendB, "high")
+	for _, f := range []*SendFuture{low, high} {
+		if sent, err := f.Wait(); err != nil || sent.Receiver != testFriendB {
+			t.Fatalf("Wait() = %+v, %v", sent, err)
+		}
+	}
+	if msg := srv.LastRequest(wcf.Functions_FUNC_SEND_TXT).GetTxt().GetMsg(); msg != "low" {
+		t.Errorf("last sent = %q, want low (high priority first)", msg)
+	}
+
+	// 单会话限速不阻塞其他会话
+	cli.SetSendPolicy(SendPolicy{GlobalRate: -1, ReceiverRate: 1, ReceiverBurst: 1, MaxJitter: -1})
+	a1 := cli.Outbox(SendPriorityNormal).Text(testFriendA, "a1")
+	a2 := cli.Outbox(SendPriorityNormal).Text(testFriendA, "a2")
+	b1 := cli.Outbox(SendPriorityNormal).Text(testFriendB, "b1")
+	start := time.Now()
+	if _, err := b1.Wait(); err != nil {
+		t.Fatalf("b1.Wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("b1 waited %v behind a throttled receiver", elapsed)
+	}
+	select {
+	case <-a2.Done():
+		t.Errorf("a2 was sent without waiting for the receiver limit")
+	default:
+	}
+	for _, f := range []*SendFuture{a1, a2} {
+		if _, err := f.Wait(); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	// 随机等待只推迟同一会话，不阻塞其他会话
+	cli.SetSendPolicy(SendPolicy{MinJitter: 600 * time.Millisecond, MaxJitter: 600 * time.Millisecond})
+	a1 = cli.Outbox(SendPriorityNormal).Text(testFriendA, "a1")
+	a2 = cli.Outbox(SendPriorityNormal).Text(testFriendA, "a2")
+	b1 = cli.Outbox(SendPriorityNormal).Text(testFriendB, "b1")
+	start = time.Now()
+	for _, f := range []*SendFuture{a1, b1} {
+		if _, err := f.Wait(); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("b1 sent after %v, want no wait behind receiver A", elapsed)
+	}
+	if _, err := a2.Wait(); err != nil || time.Since(start) < 550*time.Millisecond {
+		t.Errorf("a2 sent after %v, %v, want jitter after a1", time.Since(start), err)
+	}
+
+	// 随机等待时同一会话仍按排队顺序发送
+	cli.SetSendPolicy(SendPolicy{MaxJitter: 50 * time.Millisecond})
+	before := len(srv.Requests())
+	futures := make([]*SendFuture, 12)
+	for i := range futures {
+		futures[i] = cli.Outbox(SendPriorityNormal).Text(testFriendA, strconv.Itoa(i))
+	}
+	if _, err := waitAll(futures); err != nil {
+		t.Fatalf("waitAll() error = %v", err)
+	}
+	var order []string
+	for _, req := range srv.Requests()[before:] {
+		if req.GetFunc() == wcf.Functions_FUNC_SEND_TXT {
+			order = append(order, req.GetTxt().GetMsg())
+		}
+	}
+	if want := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("send order = %v, want %v", order, want)
+	}
+
+	// 默认不限速
+	cli.SetSendPolicy(DefaultSendPolicy)
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		if _, err := cli.SendText(testFriendA, strconv.Itoa(i)); err != nil {
+			t.Fatalf("SendText() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("10 sends with DefaultSendPolicy took %v", elapsed)
+	}
+
+	// 队列已满与关闭
+	cli.SetSendPolicy(SendPolicy{GlobalRate: 0.1, GlobalBurst: 1, ReceiverRate: -1, MaxJitter: -1, QueueSize: 1})
+	if _, err := cli.SendText(testFriendA, "x"); err != nil {
+		t.Fatalf("SendText() error = %v", err)
+	}
+	queued := cli.Outbox(SendPriorityNormal).Text(testFriendA, "y")
+	if _, err := cli.SendText(testFriendA, "z"); !errors.Is(err, ErrSendQueueFull) {
+		t.Errorf("SendText(full queue) error = %v, want ErrSendQueueFull", err)
+	}
+	cli.Close()
+	if _, err := queued.Wait(); !errors.Is(err, ErrClosed) {
+		t.Errorf("queued.Wait() after Close error = %v, want ErrClosed", err)
+	}
+	if _, err := cli.SendText(testFriendA, "after close"); !errors.Is(err, ErrClosed) {
+		t.Errorf("SendText(closed) error = %v, want ErrClosed", err)
+	}
+}
+
+func TestOfflineClient_Mentions(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	const room = "48201933156@chatroom"
+	srv.AddChatRoom(room, "测试34", testFriendA,
+		wcftest.RoomMember{Wxid: testFriendA, Name: "群里的Alice"},
+		wcftest.RoomMember{Wxid: testFriendB, Name: "Bob"},
+	)
+
+	tests := []struct {
+		name    string
+		content string
+		ats     []string
+		msg     string
+		aters   string
+	}{
+		{"Placeholder", "{at:" + testFriendA + "}周报请发到 bob@example.com", nil, "@群里的Alice\u2005周报请发到 bob@example.com", testFriendA},
+		{"Prefix", "邮件已转给 a@b.com", []string{testFriendA, testFriendB}, "@群里的Alice\u2005@Bob\u2005邮件已转给 a@b.com", testFriendA + "," + testFriendB},
+		{"Mixed", "收到，{at:" + testFriendB + "}跟进", []string{testFriendA, testFriendB}, "@群里的Alice\u2005收到，@Bob\u2005跟进", testFriendA + "," + testFriendB},
+		{"All", "{at:notify@all}开会", nil, "@所有人\u2005开会", "notify@all"},
+		{"Plain @", "a@b.com", nil, "a@b.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cli.SendText(room, tt.content, tt.ats...); err != nil {
+				t.Fatalf("SendText() error = %v", err)
+			}
+			req := srv.LastRequest(wcf.Functions_FUNC_SEND_TXT).GetTxt()
+			if req.GetMsg() != tt.msg || req.GetAters() != tt.aters {
+				t.Errorf("SEND_TXT = %q (%s), want %q (%s)", req.GetMsg(), req.GetAters(), tt.msg, tt.aters)
+			}
+		})
+	}
+
+	if m := cli.GetMember(testFriendA, true); m.Alias != "" {
+		t.Errorf("cached contact alias = %q, group nickname leaked", m.Alias)
+	}
+
+	// 拍一拍、引用与艾特使用相同的群昵称
+	pat := &Message{RoomId: room, WxId: room}
+	cli.setPatEvent(pat, &PatEvent{FromUser: testFriendB, PattedUser: testFriendA, RawTemplate: `"${` + testFriendB + `}" 拍了拍 "${` + testFriendA + `}"`})
+	if want := `"Bob" 拍了拍 "群里的Alice"`; pat.PatEvent.Template != want {
+		t.Errorf("PatEvent.Template = %q, want %q", pat.PatEvent.Template, want)
+	}
+
+	// 群昵称直接取自 RoomData，不逐个查询群成员
+	const bigRoom = "48201933157@chatroom"
+	members := []wcftest.RoomMember{{Wxid: testFriendA, Name: "大群里的Alice"}}
+	for i := 0; i < 50; i++ {
+		members = append(members, wcftest.RoomMember{Wxid: "wxid_member" + strconv.Itoa(i)})
+	}
+	srv.AddChatRoom(bigRoom, "大群", testFriendA, members...)
+	before := len(srv.Requests())
+	if name, err := cli.memberName(bigRoom, testFriendA); err != nil || name != "大群里的Alice" {
+		t.Errorf("memberName() = %q, %v, want 大群里的Alice", name, err)
+	}
+	if n := len(srv.Requests()) - before; n > 1 {
+		t.Errorf("memberName() sent %d requests, want a single RoomData query", n)
+	}
+
+	if _, err := cli.SendQuote(room, "好的", &Message{MessageId: 170, IsGroup: true, RoomId: room, WxId: testFriendA, Type: MsgTypeText, Content: "周报"}); err != nil {
+		t.Fatalf("SendQuote() error = %v", err)
+	}
+	if q, err := appmsg.Parse(srv.LastRequest(wcf.Functions_FUNC_SEND_XML).GetXml().GetContent()); err != nil || q.AppMsg.ReferMsg.DisplayName != "群里的Alice" {
+		t.Errorf("quote displayname = %+v, %v, want 群里的Alice", q, err)
+	}
+
+	cli.SetMaxTextLen(40)
+	content := "第一段：本周完成了发送队列与限速。\n\n第二段：下周计划支持长文本切分，并修复邮件地址中的 @ 被替换的问题。"
+	results, err := cli.SendLongText(room, content, testFriendA)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("SendLongText() = %d results, %v", len(results), err)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_TXT).GetTxt()
+	if !strings.HasSuffix(req.GetMsg(), "\n(3/3)") || req.GetAters() != "" {
+		t.Errorf("last part = %q (%s), want suffix (3/3) without aters", req.GetMsg(), req.GetAters())
+	}
+	// 随机等待时各段仍按顺序发出，艾特全部随第一段发出
+	cli.SetSendPolicy(SendPolicy{MaxJitter: 50 * time.Millisecond})
+	before = len(srv.Requests())
+	content = "第一段：本周完成了发送队列与限速。\n\n第二段：{at:" + testFriendB + "} 请跟进长文本切分与艾特。"
+	if results, err = cli.SendLongText(room, content, testFriendA); err != nil || len(results) != 2 {
+		t.Fatalf("SendLongText(mention) = %d results, %v", len(results), err)
+	}
+	var sent []*wcf.TextMsg
+	for _, r := range srv.Requests()[before:] {
+		if r.GetFunc() == wcf.Functions_FUNC_SEND_TXT {
+			sent = append(sent, r.GetTxt())
+		}
+	}
+	if len(sent) != 2 || !strings.HasSuffix(sent[0].GetMsg(), "(1/2)") || sent[0].GetAters() != testFriendA+","+testFriendB ||
+		!strings.Contains(sent[1].GetMsg(), "@Bob") || sent[1].GetAters() != "" {
+		t.Errorf("SEND_TXT = %v, want (1/2) with all aters, then (2/2) without aters", sent)
+	}
+	cli.SetSendPolicy(DefaultSendPolicy)
+	if results, err = cli.SendLongText(room, "短文本"); err != nil || len(results) != 1 {
+		t.Errorf("SendLongText(short) = %d results, %v", len(results), err)
+	}
+	if req = srv.LastRequest(wcf.Functions_FUNC_SEND_TXT).GetTxt(); req.GetMsg() != "短文本" {
+		t.Errorf("short text = %q, want no numbering", req.GetMsg())
+	}
+}
+
+// recvEvent 从事件管道中取一个消息事件 <跳过连接状态变化>
+func recvEvent(t *testing.T, events <-chan Event) MessageEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if me, ok := ev.(MessageEvent); ok {
+				return me
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for event")
+		}
+	}
+}
+
+func TestOfflineClient_Events(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	events := cli.Events()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	timeout := time.After(10 * time.Second)
+	for connected := false; !connected; {
+		select {
+		case ev := <-events:
+			e, ok := ev.(*LoginStateChanged)
+			connected = ok && e.State == StateConnected
+		case <-timeout:
+			t.Fatalf("timed out waiting for LoginStateChanged")
+		}
+	}
+
+	tests := []struct {
+		name  string
+		msg   *wcf.WxMsg
+		check func(t *testing.T, ev MessageEvent)
+	}{
+		{
+			name: "Text",
+			msg:  &wcf.WxMsg{Id: 150, Type: uint32(MsgTypeText), IsGroup: true, Roomid: testRoomId, Sender: testFriendA, Content: "@wcftest ping"},
+			check: func(t *testing.T, ev MessageEvent) {
+				e, ok := ev.(*TextMessage)
+				if !ok || e.Content != "@wcftest ping" || !e.RoomData.IsAtSelf {
+					t.Fatalf("event = %#v, want *TextMessage at self", ev)
+				}
+				if _, err := e.ReplyText("pong"); err != nil {
+					t.Errorf("ReplyText() error = %v", err)
+				}
+			},
+		},
+		{
+			name: "FriendRequest",
+			msg: &wcf.WxMsg{Id: 151, Type: uint32(MsgTypeFriendConfirm), Sender: "fmessage",
+				Content: `<msg fromusername="wxid_new" encryptusername="v3_abc@stranger" fromnickname="Dave" content="我是 Dave" scene="30" ticket="v4_def@stranger"/>`},
+			check: func(t *testing.T, ev MessageEvent) {
+				if e, ok := ev.(*FriendRequest); !ok || e.NewFriendReq.V3 != "v3_abc@stranger" || e.NewFriendReq.Scene != 30 {
+					t.Errorf("event = %#v, want *FriendRequest", ev)
+				}
+			},
+		},
+		{
+			name: "Pat",
+			msg: &wcf.WxMsg{Id: 152, Type: uint32(MsgTypeRevoke), IsGroup: true, Roomid: testRoomId, Sender: testRoomId,
+				Content: `<sysmsg type="pat"><pat><fromusername>wxid_pagpb98c6nj722</fromusername><chatusername>45959390469@chatroom</chatusername><pattedusername>wxid_wcftest_self</pattedusername><template><![CDATA["${wxid_pagpb98c6nj722}" 拍了拍 "${wxid_wcftest_self}"]]></template></pat></sysmsg>`},
+			check: func(t *testing.T, ev MessageEvent) {
+				if e, ok := ev.(*Pat); !ok || !e.PatEvent.IsPatted(testSelfWxid) {
+					t.Errorf("event = %#v, want *Pat", ev)
+				}
+			},
+		},
+		{
+			name: "MembersJoined",
+			msg:  &wcf.WxMsg{Id: 153, Type: uint32(MsgTypeSystem), IsGroup: true, Roomid: testRoomId, Sender: testRoomId, Content: `"Alice"邀请"Carol"加入了群聊`},
+			check: func(t *testing.T, ev MessageEvent) {
+				if e, ok := ev.(*MembersJoined); !ok || e.MemberChange.Members[0].NickName != "Carol" {
+					t.Errorf("event = %#v, want *MembersJoined", ev)
+				}
+			},
+		},
+		{
+			name: "MembersLeft",
+			msg:  &wcf.WxMsg{Id: 154, Type: uint32(MsgTypeSystem), IsGroup: true, Roomid: testRoomId, Sender: testRoomId, Content: `你将"Bob"移出了群聊`},
+			check: func(t *testing.T, ev MessageEvent) {
+				if e, ok := ev.(*MembersLeft); !ok || e.MemberChange.Kind != MemberRemoved {
+					t.Errorf("event = %#v, want *MembersLeft", ev)
+				}
+			},
+		},
+		{
+			name: "AppMessage",
+			msg: &wcf.WxMsg{Id: 155, Type: uint32(MsgTypeXML), Sender: testFriendA,
+				Content: `<msg><appmsg><title>标题</title><type>5</type><url>https://example.com</url></appmsg></msg>`},
+			check: func(t *testing.T, ev MessageEvent) {
+				if e, ok := ev.(*AppMessage); !ok || e.Link == nil || e.Msg().MessageId != 155 {
+					t.Errorf("event = %#v, want *AppMessage", ev)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Push(tt.msg)
+			tt.check(t, recvEvent(t, events))
+		})
+	}
+	select {
+	case msg := <-cli.GetMsgChan():
+		t.Errorf("GetMsgChan() received %+v after Events()", msg)
+	default:
+	}
+
+	// 关闭后事件管道随之关闭
+	cli.Close()
+	timeout = time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-events:
+			closed = !ok
+		case <-timeout:
+			t.Fatalf("events chan not closed after Close()")
+		}
+	}
+}
+
+func TestOfflineClient_Router(t *testing.T) {
+	cli, srv := newOfflineClient(t)
+	r := NewRouter(cli, 2)
+
+	var mu sync.Mutex
+	var hits []string
+	hit := func(name string) HandlerFunc {
+		return func(ctx *Context) error {
+			mu.Lock()
+			hits = append(hits, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	errs := make(chan error, 10)
+	r.OnError(func(ctx *Context, err error) { errs <- err })
+
+	r.OnText(hit("text"))
+	r.OnRegex(`^/echo (.+)`, func(ctx *Context) error {
+		ctx.Stop()
+		if ctx.Message.meta == nil { // Dispatch 构造的消息无法回复
+			return hit("echo:" + ctx.Matches[1])(ctx)
+		}
+		_, err := ctx.ReplyText(ctx.Matches[1])
+		return err
+	}).Priority(10)
+	r.OnText(func(ctx *Context) error { panic("boom") }, FromRoom("boom@chatroom")).Priority(20)
+	r.OnAtSelf(hit("at")).Priority(5)
+	r.OnPrivate(hit("admin")).Use(Auth(FromSender(testFriendA)))
+	r.Handle(hit("join-or-pat"), Or(IsMembersJoined, IsPat), Not(FromRoom("other@chatroom")))
+
+	dispatch := func(m *Message) []string {
+		mu.Lock()
+		hits = nil
+		mu.Unlock()
+		r.Dispatch(context.Background(), newMessageEvent(m))
+		mu.Lock()
+		defer mu.Unlock()
+		return hits
+	}
+	tests := []struct {
+		name string
+		msg  *Message
+		want []string
+	}{
+		{"regex stops propagation", &Message{Type: MsgTypeText, Content: "/echo hi"}, []string{"echo:hi"}},
+		{"priority order", &Message{Type: MsgTypeText, IsGroup: true, RoomId: testRoomId, Content: "hello", RoomData: &RoomData{IsAtSelf: true}}, []string{"at", "text"}},
+		{"auth passes", &Message{Type: MsgTypeText, WxId: testFriendA, Content: "hello"}, []string{"text", "admin"}},
+		{"auth rejects", &Message{Type: MsgTypeText, WxId: testFriendB, Content: "hello"}, []string{"text"}},
+		{"composed filters", &Message{Type: MsgTypeSystem, IsGroup: true, RoomId: testRoomId, MemberChange: &MemberChangeEvent{Kind: MemberJoined}}, []string{"join-or-pat"}},
+		{"composed filters reject", &Message{Type: MsgTypeSystem, IsGroup: true, RoomId: "other@chatroom", PatEvent: &PatEvent{}}, nil},
+		{"panic recovered", &Message{Type: MsgTypeText, IsGroup: true, RoomId: "boom@chatroom", Content: "hello"}, []string{"text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dispatch(tt.msg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dispatch() hits = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	var gotErrs []error
+	for len(errs) > 0 {
+		gotErrs = append(gotErrs, <-errs)
+	}
+	if len(gotErrs) != 2 || !errors.Is(gotErrs[0], ErrUnauthorized) || !errors.Is(gotErrs[1], ErrHandlerPanic) {
+		t.Errorf("OnError() errs = %v, want [ErrUnauthorized ErrHandlerPanic]", gotErrs)
+	}
+
+	// 过滤条件与中间件中注册处理器不会死锁
+	nested := NewRouter(cli, 1)
+	nested.Use(func(next HandlerFunc) HandlerFunc {
+		nested.OnError(func(ctx *Context, err error) {})
+		return next
+	})
+	nested.Handle(hit("nested"), func(ev MessageEvent) bool {
+		nested.Handle(hit("late"), IsPat)
+		return true
+	})
+	done := make(chan struct{})
+	go func() {
+		nested.Dispatch(context.Background(), newMessageEvent(&Message{Type: MsgTypeText, Content: "hello"}))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Dispatch() deadlocked when a filter or middleware registers handlers")
+	}
+
+	connected := make(chan struct{})
+	var once sync.Once
+	r.OnLoginState(func(e *LoginStateChanged) {
+		if e.State == StateConnected {
+			once.Do(func() { close(connected) })
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+	for !cli.eventsOn.Load() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := cli.handleMsg(ctx); err != nil {
+		t.Fatalf("handleMsg() error = %v", err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for OnLoginState")
+	}
+	srv.Push(&wcf.WxMsg{Id: 160, Type: uint32(MsgTypeText), Sender: testFriendB, Content: "/echo 你好"})
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.LastRequest(wcf.Functions_FUNC_SEND_TXT).GetTxt().GetMsg() != "你好" {
+		if time.Now().After(deadline) {
+			t.Fatalf("SEND_TXT request = %v, want reply 你好", srv.LastRequest(wcf.Functions_FUNC_SEND_TXT))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-runErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run() did not return after cancel")
+	}
+}
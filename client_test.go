@@ -1,3 +1,7 @@
+//go:build windows
+
+// 以下用例需要已注入的真实微信（windows），离线用例见 client_offline_test.go
+
 package wcf_rpc_sdk
 
 import (
@@ -34,7 +38,7 @@ func TestClient_SendTextAndGetMsg(t *testing.T) {
 	// 测试 SendText
 	testReceiver := "filehelper" // 微信文件助手
 	testContent := "你好，这是一条测试消息"
-	err := client.SendText(testReceiver, testContent)
+	_, err := client.SendText(testReceiver, testContent)
 	if err != nil {
 		t.Fatalf("发送消息失败: %v", err)
 	}
@@ -63,19 +67,19 @@ func TestClient_SendGroupTextAndAt(t *testing.T) {
 	testContent := "1222@23"               // 初始内容，不包含 @<Name>
 	testAt := "wxid_jj4mhsji9tjk22"        // 替换为你要@的群成员的wxid
 
-	err := client.SendText(testReceiver, testContent, testAt)
+	_, err := client.SendText(testReceiver, testContent, testAt)
 	if err != nil {
 		t.Fatalf("发送群消息失败: %v", err)
 	}
 
 	// 测试 SendText notify@all
 	testContent = "通知@所有人"
-	err = client.SendText(testReceiver, testContent, "notify@all")
+	_, err = client.SendText(testReceiver, testContent, "notify@all")
 	if err != nil {
 		t.Fatalf("发送群消息失败: %v", err)
 	}
 	testContent = "一般无at默认消息114514"
-	err = client.SendText(testReceiver, testContent, "wxid_jj4mhsji9tjk22")
+	_, err = client.SendText(testReceiver, testContent, "wxid_jj4mhsji9tjk22")
 	if err != nil {
 		t.Fatalf("发送群消息失败: %v", err)
 	}
@@ -322,7 +326,7 @@ func TestClient_ReplyText(t *testing.T) {
 
 	// 如果是文本消息，则回复
 	if msg.Content == "ping" {
-		err := msg.ReplyText("pong")
+		_, err := msg.ReplyText("pong")
 		if err != nil {
 			t.Error("回复消息错误", err)
 		}
@@ -523,7 +527,7 @@ func TestClient_SendImage(t *testing.T) {
 	//  请修改为你的本地图片路径，不存在则跳过本地图片测试
 	localImagePath := "C:\\image\\test01.png" // 修改为你的本地图片路径
 
-	err := client.SendImage(testReceiver, localImagePath)
+	_, err := client.SendImage(testReceiver, localImagePath)
 	if err != nil {
 		t.Fatalf("发送本地图片失败: %v", err)
 	}
@@ -532,7 +536,7 @@ func TestClient_SendImage(t *testing.T) {
 	// **测试发送网络图片**
 	//  使用网络图片 URL
 	networkImageUrl := "https://cdn.jsdelivr.net/gh/Xiao-yi123/WebImageFiles/146d33e6f92e89b5ae54a193ab2f7959.jpg" // 示例网络图片URL
-	err = client.SendImage(testReceiver, networkImageUrl)
+	_, err = client.SendImage(testReceiver, networkImageUrl)
 	if err != nil {
 		t.Fatalf("发送网络图片失败: %v", err)
 	}
@@ -597,7 +601,7 @@ func TestClient_SendCardMessage(t *testing.T) {
 
 	card := CardMessage{Name: "卡片测试消息", Title: "卡片测试标题", Digest: "卡片摘要", ThumbURL: "https://www.freeimg.cn/i/2024/04/22/66260f2eed1d6.jpg", URL: "https://www.bilibili.com/video/BV1oqQzYrEYn"}
 
-	err := client.SendCardMessage(testReceiver, card)
+	_, err := client.SendCardMessage(testReceiver, card)
 	if err != nil {
 		t.Fatalf("卡片消息发送失败: %v", err)
 	}
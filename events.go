@@ -0,0 +1,171 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/19 上午12:10:00
+// @Desc 类型化的事件流
+package wcf_rpc_sdk
+
+import (
+	"time"
+
+	"github.com/Clov614/logging"
+)
+
+const eventRetryWait = 100 * time.Millisecond // 事件管道满时每次重试的等待时间
+
+// Event 事件 <通过 type switch 区分>
+//
+//	for ev := range client.Events() {
+//		switch e := ev.(type) {
+//		case *wcf_rpc_sdk.TextMessage:
+//			e.ReplyText("收到：" + e.Content)
+//		case *wcf_rpc_sdk.FriendRequest:
+//			e.AcceptNewFriend()
+//		case *wcf_rpc_sdk.LoginStateChanged:
+//			log.Println(e.Prev, "->", e.State)
+//		}
+//	}
+type Event interface {
+	isEvent()
+}
+
+// MessageEvent 由消息产生的事件 <除 LoginStateChanged 外的所有事件>
+type MessageEvent interface {
+	Event
+	Msg() *Message
+}
+
+// msgEvent 消息事件的公共部分 <嵌入 *Message，可直接访问消息字段与回复方法>
+type msgEvent struct {
+	*Message
+}
+
+func (msgEvent) isEvent() {}
+
+// Msg 产生事件的消息
+func (e msgEvent) Msg() *Message {
+	return e.Message
+}
+
+type (
+	// TextMessage 文本消息 <包括引用回复，此时 Quote 不为空，Content 为回复的文本>
+	TextMessage struct{ msgEvent }
+	// ImageMessage 图片消息
+	ImageMessage struct{ msgEvent }
+	// VoiceMessage 语音消息
+	VoiceMessage struct{ msgEvent }
+	// VideoMessage 视频消息
+	VideoMessage struct{ msgEvent }
+	// EmojiMessage 表情消息
+	EmojiMessage struct{ msgEvent }
+	// LocationMessage 位置消息 <Location 不为空>
+	LocationMessage struct{ msgEvent }
+	// ContactCardMessage 名片消息 <ContactCard 不为空>
+	ContactCardMessage struct{ msgEvent }
+	// AppMessage 链接、文件、小程序、音乐、转账等 appmsg <AppMsg 不为空，子类型内容在 AppMsg.Payload>
+	AppMessage struct{ msgEvent }
+	// FriendRequest 好友请求 <NewFriendReq 不为空>
+	FriendRequest struct{ msgEvent }
+	// MembersJoined 成员加入群聊 <MemberChange 不为空>
+	MembersJoined struct{ msgEvent }
+	// MembersLeft 成员退出或被移出群聊 <MemberChange 不为空，MemberChange.Kind 区分>
+	MembersLeft struct{ msgEvent }
+	// Revoked 消息被撤回 <RevokeEvent 不为空>
+	Revoked struct{ msgEvent }
+	// Pat 拍一拍 <PatEvent 不为空>
+	Pat struct{ msgEvent }
+	// SystemNotice 其他系统提示
+	SystemNotice struct{ msgEvent }
+	// OtherMessage 未归类的消息
+	OtherMessage struct{ msgEvent }
+)
+
+// LoginStateChanged 与 wcf 的连接或微信登录状态变化
+type LoginStateChanged struct {
+	StateChange
+}
+
+func (*LoginStateChanged) isEvent() {}
+
+// newMessageEvent 按消息内容归类
+func newMessageEvent(m *Message) MessageEvent {
+	base := msgEvent{m}
+	switch {
+	case m.NewFriendReq != nil:
+		return &FriendRequest{base}
+	case m.RevokeEvent != nil:
+		return &Revoked{base}
+	case m.PatEvent != nil:
+		return &Pat{base}
+	case m.MemberChange != nil && m.MemberChange.Kind == MemberJoined:
+		return &MembersJoined{base}
+	case m.MemberChange != nil:
+		return &MembersLeft{base}
+	}
+	switch m.Type {
+	case MsgTypeText, MsgTypeXMLQuote:
+		return &TextMessage{base}
+	case MsgTypeImage:
+		return &ImageMessage{base}
+	case MsgTypeVoice:
+		return &VoiceMessage{base}
+	case MsgTypeVideo, MsgTypeShortVideo:
+		return &VideoMessage{base}
+	case MsgTypeRockPaperScissors:
+		return &EmojiMessage{base}
+	case MsgTypeLocation:
+		if m.Location != nil {
+			return &LocationMessage{base}
+		}
+	case MsgTypeBusinessCard:
+		if m.ContactCard != nil {
+			return &ContactCardMessage{base}
+		}
+	case MsgTypeSystem, MsgTypeSysNotice, MsgTypeRevoke:
+		return &SystemNotice{base}
+	}
+	if m.AppMsg != nil {
+		return &AppMessage{base}
+	}
+	return &OtherMessage{base}
+}
+
+// Events 返回事件管道 <调用后收到的消息只投递到事件管道，不再写入 GetMsgChan；管道满时重试，仍失败则丢弃该事件> <Close 后管道关闭>
+func (c *Client) Events() <-chan Event {
+	c.eventsOn.Store(true)
+	return c.events
+}
+
+// publish 投递事件 <未调用 Events 时不投递>
+func (c *Client) publish(ev Event) error {
+	if !c.eventsOn.Load() {
+		return nil
+	}
+	c.eventsMu.RLock()
+	defer c.eventsMu.RUnlock()
+	if c.evClosed {
+		return ErrClosed
+	}
+	retries := 3
+	for i := 0; i < retries; i++ {
+		select {
+		case <-c.ctx.Done():
+			return ErrClosed
+		case c.events <- ev:
+			return nil
+		case <-time.After(eventRetryWait):
+			logging.Warn("event chan is full, retrying", map[string]interface{}{"attempt": i + 1, "max": retries})
+		}
+	}
+	logging.Warn("event chan is full, drop event", map[string]interface{}{"event": ev})
+	return ErrBufferFull
+}
+
+// closeEvents 关闭事件管道 <持有写锁，等待进行中的 publish 返回>
+func (c *Client) closeEvents() {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+	if !c.evClosed {
+		c.evClosed = true
+		close(c.events)
+	}
+}
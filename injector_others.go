@@ -0,0 +1,18 @@
+//go:build !windows
+
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 上午10:12:00
+// @Desc 非 windows 平台的注入器占位（无法加载 sdk.dll）
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"github.com/Clov614/logging"
+	"runtime"
+)
+
+// Inject 非 windows 平台不支持自动注入，请以 autoInject=false 连接已注入的 wcf 服务端
+func Inject(ctx context.Context, cancel context.CancelFunc, port int, debug bool, syncChan chan struct{}) {
+	logging.Fatal("自动注入仅支持 windows 平台", 1000, map[string]interface{}{"os": runtime.GOOS, "port": port, "hint": "请使用 NewClient(..., autoInject=false, ...) 并通过 TCP_ADDR 指定 wcf 服务端地址"})
+}
@@ -7,6 +7,7 @@ package imgutil
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -25,6 +26,8 @@ var httpClient = &http.Client{
 	},
 }
 
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+
 func ImgFetch(path string) ([]byte, error) {
 	if IsURL(path) {
 		return fetchFromURL(path)
@@ -42,7 +45,7 @@ func fetchFromURL(url string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fetchFromURL: creating request: %w", err)
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36")
+	req.Header.Set("User-Agent", userAgent)
 
 	resp, err := httpClient.Do(req) // 使用全局的 http.Client
 	if err != nil {
@@ -56,6 +59,25 @@ func fetchFromURL(url string) ([]byte, error) {
 	return bytes, nil
 }
 
+// OpenURL 以流的方式打开网络地址 <调用方负责关闭 Body，ContentLength 未知时为 -1>
+func OpenURL(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OpenURL: creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OpenURL: http.Get(%q): %w", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("OpenURL: http.Get(%q): unexpected status %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
 // fetchFromFile fetches the content from the file
 func fetchFromFile(filePath string) ([]byte, error) {
 	file, err := os.Open(filePath)
@@ -79,6 +101,10 @@ const (
 	GIF  FileType = "gif"
 	BMP  FileType = "bmp"
 	TIFF FileType = "tiff"
+	WEBP FileType = "webp"
+	MP4  FileType = "mp4"
+	PDF  FileType = "pdf"
+	ZIP  FileType = "zip" // 也包括 docx、xlsx 等基于 zip 的文档
 	// 可以根据需要添加更多类型
 )
 
@@ -91,6 +117,18 @@ var imagePrefixBtsMap = map[FileType][]byte{
 	BMP:  {0x42, 0x4D},             // Windows Bitmap (bmp)，文件头：424D
 }
 
+// 其他文件的签名信息 <不参与 .dat 解码，offset 为签名在文件中的偏移>
+var fileSignatures = []struct {
+	fileType FileType
+	offset   int
+	sig      []byte
+}{
+	{WEBP, 8, []byte("WEBP")},        // RIFF????WEBP
+	{MP4, 4, []byte("ftyp")},         // ????ftyp
+	{PDF, 0, []byte("%PDF-")},        // %PDF-
+	{ZIP, 0, []byte{'P', 'K', 3, 4}}, // PK\x03\x04
+}
+
 var (
 	ErrUnknowFileType = errors.New("unknown file type")
 	ErrDecodeFail     = errors.New("decode fail") // 新增 decode fail 错误
@@ -103,9 +141,25 @@ func DetectFileType(data []byte) (FileType, error) {
 			return fileType, nil
 		}
 	}
+	for _, fs := range fileSignatures {
+		end := fs.offset + len(fs.sig)
+		if len(data) >= end && bytes.Equal(data[fs.offset:end], fs.sig) {
+			return fs.fileType, nil
+		}
+	}
 	return "", fmt.Errorf("detectFileType: %w", ErrUnknowFileType)
 }
 
+// IsImage 是否为图片类型
+func IsImage(fileType FileType) bool {
+	switch fileType {
+	case JPEG, PNG, GIF, BMP, TIFF, WEBP:
+		return true
+	default:
+		return false
+	}
+}
+
 // GetMimeTypeByFileType 根据 FileType 返回 MIME 类型
 func GetMimeTypeByFileType(fileType FileType) string {
 	switch fileType {
@@ -119,6 +173,14 @@ func GetMimeTypeByFileType(fileType FileType) string {
 		return "image/bmp"
 	case TIFF:
 		return "image/tiff"
+	case WEBP:
+		return "image/webp"
+	case MP4:
+		return "video/mp4"
+	case PDF:
+		return "application/pdf"
+	case ZIP:
+		return "application/zip"
 	default:
 		return "application/octet-stream"
 	}
@@ -137,6 +199,14 @@ func GetEtxByFileType(fileType FileType) string {
 		return ".bmp"
 	case TIFF:
 		return ".tiff"
+	case WEBP:
+		return ".webp"
+	case MP4:
+		return ".mp4"
+	case PDF:
+		return ".pdf"
+	case ZIP:
+		return ".zip"
 	default:
 		return ""
 	}
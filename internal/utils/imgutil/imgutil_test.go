@@ -34,6 +34,30 @@ func TestDetectImgType(t *testing.T) {
 	t.Log(fileType2)
 }
 
+func TestDetectFileType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want FileType
+	}{
+		{"jpg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, JPEG},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), WEBP},
+		{"mp4", []byte("\x00\x00\x00\x20ftypisom"), MP4},
+		{"pdf", []byte("%PDF-1.7\n"), PDF},
+		{"zip", []byte("PK\x03\x04\x14\x00"), ZIP},
+		{"unknown", []byte("hello"), ""},
+		{"short", []byte("RIFF"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFileType(tt.data)
+			if got != tt.want || (tt.want == "") != (err != nil) {
+				t.Errorf("DetectFileType() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
 func TestDecodeDatFile(t *testing.T) {
 	// 1. 创建一个临时的测试目录
 	outputDir := filepath.Join(os.TempDir(), "imgutil_test_output")
@@ -0,0 +1,190 @@
+package wcf_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcftest"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newCtxClient 每个用例独立的模拟服务端，避免脚本化的状态互相影响
+func newCtxClient(t *testing.T) (*wcftest.Server, *wcf.Client) {
+	t.Helper()
+	srv, err := wcftest.NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	c, err := wcf.NewWCF(srv.Addr())
+	if err != nil {
+		_ = srv.Close()
+		t.Fatalf("NewWCF() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = srv.Close()
+	})
+	return srv, c
+}
+
+func TestClient_CtxOK(t *testing.T) {
+	_, c := newCtxClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ok, err := c.IsLoginCtx(ctx)
+	if err != nil || !ok {
+		t.Errorf("IsLoginCtx() = %v, %v, want true, nil", ok, err)
+	}
+	rows, err := c.ExecDBQueryCtx(ctx, "MicroMsg.db", "select * from Contact where UserName = 'nobody';")
+	if err != nil || len(rows) != 0 {
+		t.Errorf("ExecDBQueryCtx() = %v, %v, want empty, nil", rows, err)
+	}
+	if status, err := c.SendTxtCtx(ctx, "hi", "filehelper", nil); err != nil || status != 0 {
+		t.Errorf("SendTxtCtx() = %d, %v, want 0, nil", status, err)
+	}
+}
+
+func TestClient_CtxStatusError(t *testing.T) {
+	srv, c := newCtxClient(t)
+	srv.SetStatus(wcf.Functions_FUNC_SEND_TXT, -1)
+	srv.SetStatus(wcf.Functions_FUNC_ADD_ROOM_MEMBERS, 0)
+
+	status, err := c.SendTxtCtx(context.Background(), "hi", "filehelper", nil)
+	var se *wcf.StatusError
+	if !errors.As(err, &se) || se.Status != -1 || se.Func != wcf.Functions_FUNC_SEND_TXT || status != -1 {
+		t.Errorf("SendTxtCtx() = %d, %v, want StatusError -1", status, err)
+	}
+	if _, err = c.AddChatRoomMembersCtx(context.Background(), "1@chatroom", []string{"wxid_a"}); !wcf.IsStatusError(err) {
+		t.Errorf("AddChatRoomMembersCtx() error = %v, want StatusError", err)
+	}
+	// 旧接口保持原有返回值
+	if got := c.SendTxt("hi", "filehelper", nil); got != -1 {
+		t.Errorf("SendTxt() = %d, want -1", got)
+	}
+}
+
+func TestClient_CtxTimeout(t *testing.T) {
+	srv, c := newCtxClient(t)
+	srv.Handle(wcf.Functions_FUNC_GET_CONTACTS, func(req *wcf.Request) *wcf.Response {
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetContactsCtx(ctx)
+	if !errors.Is(err, wcf.ErrTimeout) {
+		t.Errorf("GetContactsCtx() error = %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("GetContactsCtx() returned after %v, deadline not respected", elapsed)
+	}
+
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+	if _, err = c.IsLoginCtx(cctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("IsLoginCtx(canceled) error = %v, want context.Canceled", err)
+	}
+}
+
+func TestClient_CtxDecode(t *testing.T) {
+	srv, c := newCtxClient(t)
+	srv.Handle(wcf.Functions_FUNC_IS_LOGIN, func(req *wcf.Request) *wcf.Response {
+		return &wcf.Response{Msg: &wcf.Response_Str{Str: "not a status"}}
+	})
+	if _, err := c.IsLoginCtx(context.Background()); !errors.Is(err, wcf.ErrDecode) {
+		t.Errorf("IsLoginCtx() error = %v, want ErrDecode", err)
+	}
+}
+
+func TestClient_CtxClosed(t *testing.T) {
+	_, c := newCtxClient(t)
+	_ = c.Close()
+	if _, err := c.GetSelfWXIDCtx(context.Background()); !errors.Is(err, wcf.ErrClosed) {
+		t.Errorf("GetSelfWXIDCtx() error = %v, want ErrClosed", err)
+	}
+	if got := c.GetSelfWXID(); got != "" {
+		t.Errorf("GetSelfWXID() = %q, want empty", got)
+	}
+}
+
+// TestClient_ConcurrentCalls 并发调用不同接口，每个调用都必须拿到自己的应答
+func TestClient_ConcurrentCalls(t *testing.T) {
+	srv, c := newCtxClient(t)
+	const workers, rounds = 16, 50
+	for i := 0; i < workers; i++ {
+		srv.AddContact(wcftest.Contact{Wxid: fmt.Sprintf("wxid_%02d", i), NickName: fmt.Sprintf("nick_%02d", i)})
+	}
+	srv.SetStatus(wcf.Functions_FUNC_SEND_TXT, 0)
+	srv.SetStatus(wcf.Functions_FUNC_FORWARD_MSG, 1)
+	srv.Handle(wcf.Functions_FUNC_EXEC_DB_QUERY, func(req *wcf.Request) *wcf.Response {
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond) // 打乱调度
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, workers*rounds)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			wxid, nick := fmt.Sprintf("wxid_%02d", i), fmt.Sprintf("nick_%02d", i)
+			ctx := context.Background()
+			for r := 0; r < rounds; r++ {
+				switch (i + r) % 4 {
+				case 0:
+					rows, err := c.ExecDBQueryCtx(ctx, "MicroMsg.db", "select NickName from Contact where UserName = '"+wxid+"';")
+					if err != nil || len(rows) != 1 || string(rows[0].GetFields()[0].GetContent()) != nick {
+						errCh <- fmt.Errorf("worker %d ExecDBQueryCtx() = %v, %v", i, rows, err)
+					}
+				case 1:
+					if got, err := c.GetSelfWXIDCtx(ctx); err != nil || got != "wxid_wcftest_self" {
+						errCh <- fmt.Errorf("worker %d GetSelfWXIDCtx() = %q, %v", i, got, err)
+					}
+				case 2:
+					if status, err := c.SendTxtCtx(ctx, nick, wxid, nil); err != nil || status != 0 {
+						errCh <- fmt.Errorf("worker %d SendTxtCtx() = %d, %v", i, status, err)
+					}
+				case 3:
+					if status, err := c.ForwardMsgCtx(ctx, uint64(i), wxid); err != nil || status != 1 {
+						errCh <- fmt.Errorf("worker %d ForwardMsgCtx() = %d, %v", i, status, err)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+}
+
+// TestClient_StaleResponse 超时调用的应答稍后到达时不会被下一次调用读到
+func TestClient_StaleResponse(t *testing.T) {
+	srv, c := newCtxClient(t)
+	srv.Handle(wcf.Functions_FUNC_IS_LOGIN, func(req *wcf.Request) *wcf.Response {
+		time.Sleep(300 * time.Millisecond)
+		return &wcf.Response{Msg: &wcf.Response_Status{Status: 0}}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.IsLoginCtx(ctx); !errors.Is(err, wcf.ErrTimeout) {
+		t.Fatalf("IsLoginCtx() error = %v, want ErrTimeout", err)
+	}
+	srv.Handle(wcf.Functions_FUNC_IS_LOGIN, nil)
+	for i := 0; i < 3; i++ {
+		if ok, err := c.IsLoginCtx(context.Background()); err != nil || !ok {
+			t.Errorf("IsLoginCtx() #%d = %v, %v, want true, nil", i, ok, err)
+		}
+	}
+	if got, err := c.GetSelfWXIDCtx(context.Background()); err != nil || got != "wxid_wcftest_self" {
+		t.Errorf("GetSelfWXIDCtx() = %q, %v", got, err)
+	}
+}
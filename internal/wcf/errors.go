@@ -0,0 +1,43 @@
+package wcf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.nanomsg.org/mangos/v3"
+)
+
+var (
+	ErrTimeout = errors.New("wcf: rpc timeout")            // 发送或接收超时（含 ctx 截止时间到达）
+	ErrClosed  = errors.New("wcf: connection closed")      // 连接已关闭
+	ErrDecode  = errors.New("wcf: decode response failed") // 应答无法解析或类型不符
+)
+
+// StatusError 接口返回了表示失败的状态码
+type StatusError struct {
+	Func   Functions
+	Status int32
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("wcf: %s failed, status: %d", e.Func, e.Status)
+}
+
+// IsStatusError 是否为状态码错误（即通信正常，但接口执行失败）
+func IsStatusError(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se)
+}
+
+// wrapErr 将 mangos / context 的错误映射为包内的类型错误
+func wrapErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, mangos.ErrSendTimeout), errors.Is(err, mangos.ErrRecvTimeout):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.Is(err, mangos.ErrClosed):
+		return fmt.Errorf("%w: %w", ErrClosed, err)
+	}
+	return err
+}
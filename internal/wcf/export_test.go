@@ -0,0 +1,6 @@
+package wcf
+
+// SetTestAddr 供外部测试包（wcf_test）在未设置 TEST_ADDR 时指向伪服务端
+func SetTestAddr(addr string) {
+	testAddr = addr
+}
@@ -0,0 +1,37 @@
+package wcf_test
+
+import (
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcftest"
+	"os"
+	"testing"
+)
+
+// TestMain 未设置 TEST_ADDR 时，所有用例运行在进程内的伪 wcf 服务端上
+func TestMain(m *testing.M) {
+	if os.Getenv("TEST_ADDR") != "" {
+		os.Exit(m.Run())
+	}
+	srv, err := wcftest.NewServer("")
+	if err != nil {
+		fmt.Println("start wcftest server:", err)
+		os.Exit(1)
+	}
+	srv.AddContact(wcftest.Contact{Wxid: "wxid_pagpb98c6nj722", NickName: "Alice", SmallHeadURL: "https://wx.qlogo.cn/alice/132", BigHeadURL: "https://wx.qlogo.cn/alice/0"})
+	srv.AddContact(wcftest.Contact{Wxid: "wxid_jj4mhsji9tjk22", NickName: "Bob"})
+	srv.AddChatRoom("45959390469@chatroom", "测试12", "wxid_pagpb98c6nj722",
+		wcftest.RoomMember{Wxid: "wxid_pagpb98c6nj722", Name: "Alice"},
+		wcftest.RoomMember{Wxid: "wxid_jj4mhsji9tjk22", Name: "Bob"},
+	)
+	srv.Handle(wcf.Functions_FUNC_ENABLE_RECV_TXT, func(req *wcf.Request) *wcf.Response {
+		// 每次开启接收时推送一条消息，供 OnMSG 用例消费
+		srv.Push(&wcf.WxMsg{Id: 1, Type: 1, Ts: 1736867633, Content: "ping", Sender: "wxid_pagpb98c6nj722"})
+		return nil
+	})
+	wcf.SetTestAddr(srv.Addr())
+
+	code := m.Run()
+	_ = srv.Close()
+	os.Exit(code)
+}
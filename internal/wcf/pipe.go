@@ -0,0 +1,56 @@
+package wcf
+
+import (
+	"go.nanomsg.org/mangos/v3"
+	"sync"
+	"time"
+)
+
+// pair1 只允许一个对端：旧连接尚未被服务端移除时，新的连接会被服务端直接关闭，
+// 其上已发出的请求不会得到应答。pipeWatch 记录连接的建立与断开，用于：
+//  1. 拨号后等待连接稳定再发送请求
+//  2. 等待应答期间连接断开时立即返回，而不是一直等待
+type pipeWatch struct {
+	mu       sync.Mutex
+	attached int    // 当前连接数
+	detached uint64 // 累计断开次数
+}
+
+const (
+	pipeSettleQuiet   = 50 * time.Millisecond // 连接保持该时长无变化视为稳定
+	pipeSettleTimeout = 2 * time.Second
+)
+
+func (w *pipeWatch) hook(ev mangos.PipeEvent, _ mangos.Pipe) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch ev {
+	case mangos.PipeEventAttached:
+		w.attached++
+	case mangos.PipeEventDetached:
+		if w.attached > 0 {
+			w.attached--
+		}
+		w.detached++
+	}
+}
+
+// state 当前是否已连接，以及累计断开次数
+func (w *pipeWatch) state() (bool, uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.attached > 0, w.detached
+}
+
+// settle 等待连接建立并稳定 <超时后直接返回，由后续调用的超时处理>
+func (w *pipeWatch) settle() {
+	deadline := time.Now().Add(pipeSettleTimeout)
+	for time.Now().Before(deadline) {
+		up, gen := w.state()
+		time.Sleep(pipeSettleQuiet)
+		up2, gen2 := w.state()
+		if up && up2 && gen == gen2 {
+			return
+		}
+	}
+}
@@ -2,6 +2,7 @@ package wcf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Clov614/logging"
 	"go.nanomsg.org/mangos/v3"
@@ -12,15 +13,30 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// pollInterval 无截止时间时，阻塞收发按该间隔检查 ctx 是否取消
+const pollInterval = 200 * time.Millisecond
+
+// msgRecvTimeout 消息 socket 单次接收的超时时间，超时后检查 ctx 与 RecvTxt 再继续接收
+const msgRecvTimeout = 5 * time.Second
+
 type Client struct {
 	add                string
 	socket             protocol.Socket
-	RecvTxt            bool
+	RecvTxt            atomic.Bool // 是否接收消息 <OnMSG 在其为 false 时退出>
 	ContactsMap        []map[string]string
 	MessageCallbackUrl string
-	mu                 sync.Mutex
+	mu                 sync.Mutex      // 请求/应答互斥，保护 stale 相关字段
+	stale              int             // 已放弃等待、应答尚未读取的请求数
+	stalePipes         *pipeWatch      // stale 所属的连接
+	staleGen           uint64          // stale 所属连接的断开计数
+	pipes              *pipeWatch      // 命令 socket 的连接状态
+	sockMu             sync.RWMutex    // 保护 socket / msgSocket 的替换与关闭
+	msgSocket          protocol.Socket // 正在接收消息的 socket <OnMSG 期间有效>
+	closed             bool
 }
 
 func (c *Client) conn() error {
@@ -28,125 +44,314 @@ func (c *Client) conn() error {
 	if err != nil {
 		return err
 	}
+	pipes := &pipeWatch{}
+	socket.SetPipeEventHook(pipes.hook)
 	err = socket.Dial(c.add)
 	if err != nil {
+		_ = socket.Close()
 		return err
 	}
-	c.socket = socket
+	pipes.settle() // 等待服务端接受连接
+	c.socket, c.pipes = socket, pipes
 	return err
 }
 
-func (c *Client) send(data []byte) error {
+// sock 当前的命令 socket 及其连接状态
+func (c *Client) sock() (protocol.Socket, *pipeWatch) {
+	c.sockMu.RLock()
+	defer c.sockMu.RUnlock()
+	return c.socket, c.pipes
+}
+
+func (c *Client) isClosed() bool {
+	c.sockMu.RLock()
+	defer c.sockMu.RUnlock()
+	return c.closed
+}
+
+// Reconnect 关闭当前命令 socket 并重新拨号 <正在进行的调用将返回 ErrClosed>
+func (c *Client) Reconnect() error {
+	c.sockMu.Lock()
+	defer c.sockMu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if c.socket != nil {
+		_ = c.socket.Close()
+	}
+	if c.msgSocket != nil {
+		_ = c.msgSocket.Close() // 使 OnMSG 退出，由调用方重新开启
+	}
+	if err := c.conn(); err != nil {
+		return fmt.Errorf("redial %s: %w", c.add, err)
+	}
+	return nil
+}
+
+// sendCtx 发送请求，ctx 的截止时间映射为 mangos 的发送超时 <调用方需持有 mu>
+func (c *Client) sendCtx(ctx context.Context, socket protocol.Socket, data []byte) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return wrapErr(err)
+		}
+		if err := socket.SetOption(mangos.OptionSendDeadline, nextDeadline(ctx)); err != nil {
+			return wrapErr(err)
+		}
+		err := socket.Send(data)
+		if errors.Is(err, mangos.ErrSendTimeout) {
+			continue // 由下一轮检查 ctx
+		}
+		return wrapErr(err)
+	}
+}
+
+// Recv 接收一条应答
+func (c *Client) Recv() (*Response, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.socket.Send(data)
+	socket, _ := c.sock()
+	return c.recvCtx(context.Background(), socket, nil, 0)
 }
 
-func (c *Client) Recv() (*Response, error) {
+// recvCtx 接收应答，ctx 的截止时间映射为 mangos 的接收超时 <调用方需持有 mu>
+// pipes 不为空时，连接在发出请求（断开计数为 gen）后断开则返回 ErrClosed
+func (c *Client) recvCtx(ctx context.Context, socket protocol.Socket, pipes *pipeWatch, gen uint64) (*Response, error) {
 	msg := &Response{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return msg, wrapErr(err)
+		}
+		if pipes != nil {
+			if _, g := pipes.state(); g != gen {
+				return msg, fmt.Errorf("%w: connection lost while waiting for response", ErrClosed)
+			}
+		}
+		if err := socket.SetOption(mangos.OptionRecvDeadline, nextDeadline(ctx)); err != nil {
+			return msg, wrapErr(err)
+		}
+		recv, err := socket.Recv()
+		if errors.Is(err, mangos.ErrRecvTimeout) {
+			continue
+		}
+		if err != nil {
+			return msg, wrapErr(err)
+		}
+		if err = proto.Unmarshal(recv, msg); err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		return msg, nil
+	}
+}
+
+// call 发送请求并等待对应的应答
+// 整个请求/应答过程持有 mu，并发调用按顺序执行；服务端按顺序应答，
+// 因此之前超时或取消而未读取的应答（stale 个）会先被丢弃，再读取本次的应答
+func (c *Client) call(ctx context.Context, req *cmdMSG) (*Response, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	recv, err := c.socket.Recv()
+	socket, pipes := c.sock()
+	_, gen := pipes.state()
+	if pipes != c.stalePipes || gen != c.staleGen { // 重连或连接断开后，旧连接上的应答不会再到达
+		c.stalePipes, c.staleGen, c.stale = pipes, gen, 0
+	}
+	if err := c.sendCtx(ctx, socket, req.build()); err != nil {
+		return nil, fmt.Errorf("send %s: %w", req.GetFunc(), err)
+	}
+	for {
+		recv, err := c.recvCtx(ctx, socket, pipes, gen)
+		if errors.Is(err, ErrDecode) && c.stale > 0 {
+			c.stale-- // 无法解析的旧应答
+			continue
+		}
+		if errors.Is(err, ErrTimeout) || errors.Is(err, context.Canceled) {
+			c.stale++ // 请求已发出，应答稍后到达时丢弃
+		}
+		if err != nil {
+			return nil, fmt.Errorf("recv %s: %w", req.GetFunc(), err)
+		}
+		if c.stale > 0 {
+			c.stale--
+			logging.Debug("drop stale response", map[string]interface{}{"func": recv.GetFunc().String(), "want": req.GetFunc().String()})
+			continue
+		}
+		if fn := recv.GetFunc(); fn != Functions_FUNC_RESERVED && fn != req.GetFunc() {
+			logging.Warn("drop mismatched response", map[string]interface{}{"func": fn.String(), "want": req.GetFunc().String()})
+			continue
+		}
+		return recv, nil
+	}
+}
+
+// callStatus 调用只返回状态码的接口，状态码不为 success 时返回 *StatusError
+func (c *Client) callStatus(ctx context.Context, req *cmdMSG, success int32) (int32, error) {
+	recv, err := c.call(ctx, req)
 	if err != nil {
-		return msg, err
+		return 0, err
+	}
+	if _, ok := recv.GetMsg().(*Response_Status); !ok && recv.GetMsg() != nil {
+		return 0, fmt.Errorf("%w: %s want status, got %T", ErrDecode, req.GetFunc(), recv.GetMsg())
+	}
+	if recv.GetStatus() != success {
+		return recv.GetStatus(), &StatusError{Func: req.GetFunc(), Status: recv.GetStatus()}
 	}
-	err = proto.Unmarshal(recv, msg)
-	return msg, err
+	return recv.GetStatus(), nil
 }
 
-// Close 退出
+// expect 检查应答的类型，空应答视为空结果
+func expect[T any](fn Functions, recv *Response) error {
+	if recv.GetMsg() == nil {
+		return nil
+	}
+	if _, ok := recv.GetMsg().(T); !ok {
+		return fmt.Errorf("%w: %s got %T", ErrDecode, fn, recv.GetMsg())
+	}
+	return nil
+}
+
+// logErr 兼容旧接口的错误日志（状态码错误由调用方通过返回值判断，不打印）
+func logErr(err error, msg string) {
+	if err != nil && !IsStatusError(err) {
+		logging.ErrorWithErr(err, msg)
+	}
+}
+
+// Close 退出 <同时关闭消息 socket>
 func (c *Client) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.sockMu.Lock()
+	defer c.sockMu.Unlock()
+	c.closed = true
+	if c.msgSocket != nil {
+		_ = c.msgSocket.Close()
+	}
 	return c.socket.Close()
 }
 
 // IsLogin 查看是否登录
 func (c *Client) IsLogin() bool {
-	err := c.send(genFunReq(Functions_FUNC_IS_LOGIN).build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
-	}
-	recv, err := c.Recv()
+	ok, err := c.IsLoginCtx(context.Background())
+	logErr(err, "internal is_login err")
+	return ok
+}
+
+// IsLoginCtx 查看是否登录
+func (c *Client) IsLoginCtx(ctx context.Context) (bool, error) {
+	recv, err := c.call(ctx, genFunReq(Functions_FUNC_IS_LOGIN))
 	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+		return false, err
 	}
-	if recv.GetStatus() == 1 {
-		return true
+	if err = expect[*Response_Status](Functions_FUNC_IS_LOGIN, recv); err != nil {
+		return false, err
 	}
-	return false
+	return recv.GetStatus() == 1, nil
 }
 
 // GetSelfWXID 获取登录的id
 func (c *Client) GetSelfWXID() string {
-	err := c.send(genFunReq(Functions_FUNC_GET_SELF_WXID).build())
+	wxid, err := c.GetSelfWXIDCtx(context.Background())
+	logErr(err, "internal get self_WXID err")
+	return wxid
+}
+
+// GetSelfWXIDCtx 获取登录的id
+func (c *Client) GetSelfWXIDCtx(ctx context.Context) (string, error) {
+	recv, err := c.call(ctx, genFunReq(Functions_FUNC_GET_SELF_WXID))
 	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+		return "", err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal get self_WXID err")
+	if err = expect[*Response_Str](Functions_FUNC_GET_SELF_WXID, recv); err != nil {
+		return "", err
 	}
-	return recv.GetStr()
+	return recv.GetStr(), nil
 }
 
 // GetMsgTypes 获取消息类型
 func (c *Client) GetMsgTypes() map[int32]string {
-	err := c.send(genFunReq(Functions_FUNC_GET_MSG_TYPES).build())
+	types, err := c.GetMsgTypesCtx(context.Background())
+	logErr(err, "internal GetMsgTypes err")
+	return types
+}
+
+// GetMsgTypesCtx 获取消息类型
+func (c *Client) GetMsgTypesCtx(ctx context.Context) (map[int32]string, error) {
+	recv, err := c.call(ctx, genFunReq(Functions_FUNC_GET_MSG_TYPES))
 	if err != nil {
-		logging.ErrorWithErr(err, "internal GetMsgTypes err")
+		return nil, err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal GetMsgTypes err")
+	if err = expect[*Response_Types](Functions_FUNC_GET_MSG_TYPES, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetTypes().GetTypes()
+	return recv.GetTypes().GetTypes(), nil
 }
 
 // GetContacts 获取通讯录
 func (c *Client) GetContacts() []*RpcContact {
-	err := c.send(genFunReq(Functions_FUNC_GET_CONTACTS).build())
+	contacts, err := c.GetContactsCtx(context.Background())
+	logErr(err, "internal GetContacts err")
+	return contacts
+}
+
+// GetContactsCtx 获取通讯录
+func (c *Client) GetContactsCtx(ctx context.Context) ([]*RpcContact, error) {
+	recv, err := c.call(ctx, genFunReq(Functions_FUNC_GET_CONTACTS))
 	if err != nil {
-		logging.ErrorWithErr(err, "internal GetContacts err")
+		return nil, err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal GetContacts err")
+	if err = expect[*Response_Contacts](Functions_FUNC_GET_CONTACTS, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetContacts().GetContacts()
+	return recv.GetContacts().GetContacts(), nil
 }
 
 // GetDBNames 获取数据库名
 func (c *Client) GetDBNames() []string {
-	err := c.send(genFunReq(Functions_FUNC_GET_DB_NAMES).build())
+	names, err := c.GetDBNamesCtx(context.Background())
+	logErr(err, "internal GetDBNames err")
+	return names
+}
+
+// GetDBNamesCtx 获取数据库名
+func (c *Client) GetDBNamesCtx(ctx context.Context) ([]string, error) {
+	recv, err := c.call(ctx, genFunReq(Functions_FUNC_GET_DB_NAMES))
 	if err != nil {
-		logging.ErrorWithErr(err, "internal GetDBNames err")
+		return nil, err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal GetDBNames err")
+	if err = expect[*Response_Dbs](Functions_FUNC_GET_DB_NAMES, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetDbs().Names
+	return recv.GetDbs().GetNames(), nil
 }
 
 // GetDBTables 获取表
 func (c *Client) GetDBTables(tab string) []*DbTable {
+	tables, err := c.GetDBTablesCtx(context.Background(), tab)
+	logErr(err, "internal GetDBTables err")
+	return tables
+}
+
+// GetDBTablesCtx 获取表
+func (c *Client) GetDBTablesCtx(ctx context.Context, tab string) ([]*DbTable, error) {
 	req := genFunReq(Functions_FUNC_GET_DB_TABLES)
 	str := &Request_Str{Str: tab}
 	req.Msg = str
-	err := c.send(req.build())
+	recv, err := c.call(ctx, req)
 	if err != nil {
-		logging.ErrorWithErr(err, "internal GetDBTables err")
+		return nil, err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal GetDBTables err")
+	if err = expect[*Response_Tables](Functions_FUNC_GET_DB_TABLES, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetTables().GetTables()
+	return recv.GetTables().GetTables(), nil
 }
 
 // ExecDBQuery 执行sql
 func (c *Client) ExecDBQuery(db, sql string) []*DbRow {
+	rows, err := c.ExecDBQueryCtx(context.Background(), db, sql)
+	logErr(err, "internal ExecDBQuery err")
+	return rows
+}
+
+// ExecDBQueryCtx 执行sql <查询无结果时返回空切片与 nil 错误>
+func (c *Client) ExecDBQueryCtx(ctx context.Context, db, sql string) ([]*DbRow, error) {
 	req := genFunReq(Functions_FUNC_EXEC_DB_QUERY)
 	q := Request_Query{
 		Query: &DbQuery{
@@ -155,19 +360,25 @@ func (c *Client) ExecDBQuery(db, sql string) []*DbRow {
 		},
 	}
 	req.Msg = &q
-	err := c.send(req.build())
+	recv, err := c.call(ctx, req)
 	if err != nil {
-		logging.ErrorWithErr(err, "internal ExecDBQuery err")
+		return nil, err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal ExecDBQuery err")
+	if err = expect[*Response_Rows](Functions_FUNC_EXEC_DB_QUERY, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetRows().GetRows()
+	return recv.GetRows().GetRows(), nil
 }
 
 // AcceptFriend 接收好友请求
 func (c *Client) AcceptFriend(v3, v4 string, scene int64) int32 {
+	status, err := c.AcceptFriendCtx(context.Background(), v3, v4, scene)
+	logErr(err, "internal AcceptFriend err")
+	return status
+}
+
+// AcceptFriendCtx 接收好友请求 <1 为成功>
+func (c *Client) AcceptFriendCtx(ctx context.Context, v3, v4 string, scene int64) (int32, error) {
 	req := genFunReq(Functions_FUNC_ACCEPT_FRIEND)
 	q := Request_V{
 		V: &Verification{
@@ -177,36 +388,24 @@ func (c *Client) AcceptFriend(v3, v4 string, scene int64) int32 {
 		}}
 
 	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal AcceptFriend err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal AcceptFriend err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
+// AddChatroomMembers 添加群成员 <wxIDs 以逗号分隔>
+// Deprecated: 与 AddChatRoomMembers 重复，请使用 AddChatRoomMembers
 func (c *Client) AddChatroomMembers(roomID, wxIDs string) int32 {
-	req := genFunReq(Functions_FUNC_ADD_ROOM_MEMBERS)
-	q := Request_M{
-		M: &MemberMgmt{Roomid: roomID, Wxids: wxIDs},
-	}
-	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal AddChatroomMembers err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal AddChatroomMembers err")
-	}
-	return recv.GetStatus()
+	return c.AddChatRoomMembers(roomID, strings.Split(wxIDs, ","))
 }
 
 // ReceiveTransfer 接收转账
 func (c *Client) ReceiveTransfer(wxid, tfid, taid string) int32 {
+	status, err := c.ReceiveTransferCtx(context.Background(), wxid, tfid, taid)
+	logErr(err, "internal ReceiveTransfer err")
+	return status
+}
+
+// ReceiveTransferCtx 接收转账 <1 为成功>
+func (c *Client) ReceiveTransferCtx(ctx context.Context, wxid, tfid, taid string) (int32, error) {
 	req := genFunReq(Functions_FUNC_RECV_TRANSFER)
 	q := Request_Tf{
 		Tf: &Transfer{
@@ -216,127 +415,134 @@ func (c *Client) ReceiveTransfer(wxid, tfid, taid string) int32 {
 		},
 	}
 	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal ReceiveTransfer err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal ReceiveTransfer err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // RefreshPYQ 刷新朋友圈
 // Deprecated
 func (c *Client) RefreshPYQ() int32 {
+	status, err := c.RefreshPYQCtx(context.Background())
+	logErr(err, "internal RefreshPYQ err")
+	return status
+}
+
+// RefreshPYQCtx 刷新朋友圈 <1 为成功>
+// Deprecated
+func (c *Client) RefreshPYQCtx(ctx context.Context) (int32, error) {
 	req := genFunReq(Functions_FUNC_REFRESH_PYQ)
 	q := Request_Ui64{
 		Ui64: 0,
 	}
 	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal RefreshPYQ err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal RefreshPYQ err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // DecryptImage 解密图片 加密路径，解密路径
 func (c *Client) DecryptImage(src, dst string) string {
+	path, err := c.DecryptImageCtx(context.Background(), src, dst)
+	logErr(err, "internal DecryptImage err")
+	return path
+}
+
+// DecryptImageCtx 解密图片 <返回解密后的路径，空字符串为失败>
+func (c *Client) DecryptImageCtx(ctx context.Context, src, dst string) (string, error) {
 	req := genFunReq(Functions_FUNC_DECRYPT_IMAGE)
 	q := Request_Dec{
 		Dec: &DecPath{Src: src, Dst: dst},
 	}
 	req.Msg = &q
-	err := c.send(req.build())
+	recv, err := c.call(ctx, req)
 	if err != nil {
-		logging.ErrorWithErr(err, "internal DecryptImage err")
+		return "", err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal DecryptImage err")
+	if err = expect[*Response_Str](Functions_FUNC_DECRYPT_IMAGE, recv); err != nil {
+		return "", err
 	}
-
-	return recv.String()
+	return recv.GetStr(), nil
 }
 
 // AddChatRoomMembers 添加群成员
 func (c *Client) AddChatRoomMembers(roomId string, wxIds []string) int32 {
+	status, err := c.AddChatRoomMembersCtx(context.Background(), roomId, wxIds)
+	logErr(err, "internal AddChatRoomMembers err")
+	return status
+}
+
+// AddChatRoomMembersCtx 添加群成员 <1 为成功>
+func (c *Client) AddChatRoomMembersCtx(ctx context.Context, roomId string, wxIds []string) (int32, error) {
 	req := genFunReq(Functions_FUNC_ADD_ROOM_MEMBERS)
 	q := Request_M{
 		M: &MemberMgmt{Roomid: roomId,
 			Wxids: strings.Join(wxIds, ",")},
 	}
 	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal AddChatRoomMembers err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal AddChatRoomMembers err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // InvChatRoomMembers 邀请群成员
 func (c *Client) InvChatRoomMembers(roomId string, wxIds []string) int32 {
+	status, err := c.InvChatRoomMembersCtx(context.Background(), roomId, wxIds)
+	logErr(err, "internal InvChatRoomMembers err")
+	return status
+}
+
+// InvChatRoomMembersCtx 邀请群成员 <1 为成功>
+func (c *Client) InvChatRoomMembersCtx(ctx context.Context, roomId string, wxIds []string) (int32, error) {
 	req := genFunReq(Functions_FUNC_INV_ROOM_MEMBERS)
 	q := Request_M{
 		M: &MemberMgmt{Roomid: roomId,
 			Wxids: strings.Join(wxIds, ",")},
 	}
 	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal InvChatRoomMembers err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal InvChatRoomMembers err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // DelChatRoomMembers 删除群成员
 func (c *Client) DelChatRoomMembers(roomId string, wxIds []string) int32 {
+	status, err := c.DelChatRoomMembersCtx(context.Background(), roomId, wxIds)
+	logErr(err, "internal DelChatRoomMembers err")
+	return status
+}
+
+// DelChatRoomMembersCtx 删除群成员 <1 为成功>
+func (c *Client) DelChatRoomMembersCtx(ctx context.Context, roomId string, wxIds []string) (int32, error) {
 	req := genFunReq(Functions_FUNC_DEL_ROOM_MEMBERS)
 	q := Request_M{
 		M: &MemberMgmt{Roomid: roomId,
 			Wxids: strings.Join(wxIds, ",")},
 	}
 	req.Msg = &q
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal DelChatRoomMembers err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal DelChatRoomMembers err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // GetUserInfo 获取自己的信息
 func (c *Client) GetUserInfo() *UserInfo {
-	err := c.send(genFunReq(Functions_FUNC_GET_USER_INFO).build())
+	ui, err := c.GetUserInfoCtx(context.Background())
+	logErr(err, "internal getFriend err")
+	return ui
+}
+
+// GetUserInfoCtx 获取自己的信息
+func (c *Client) GetUserInfoCtx(ctx context.Context) (*UserInfo, error) {
+	recv, err := c.call(ctx, genFunReq(Functions_FUNC_GET_USER_INFO))
 	if err != nil {
-		logging.ErrorWithErr(err, "internal getFriend err")
+		return nil, err
 	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal getFriend err")
+	if err = expect[*Response_Ui](Functions_FUNC_GET_USER_INFO, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetUi()
+	return recv.GetUi(), nil
 }
 
 // SendTxt 发送文本内容
 func (c *Client) SendTxt(msg string, receiver string, ates []string) int32 {
+	status, err := c.SendTxtCtx(context.Background(), msg, receiver, ates)
+	logErr(err, "internal SendTxt err")
+	return status
+}
+
+// SendTxtCtx 发送文本内容 <0 为成功>
+func (c *Client) SendTxtCtx(ctx context.Context, msg string, receiver string, ates []string) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_TXT)
 	req.Msg = &Request_Txt{
 		Txt: &TextMsg{
@@ -345,19 +551,18 @@ func (c *Client) SendTxt(msg string, receiver string, ates []string) int32 {
 			Aters:    strings.Join(ates, ","),
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendTxt err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendTxt err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 0)
 }
 
 // ForwardMsg 转发消息
 func (c *Client) ForwardMsg(Id uint64, receiver string) int32 {
+	status, err := c.ForwardMsgCtx(context.Background(), Id, receiver)
+	logErr(err, "internal ForwardMsg err")
+	return status
+}
+
+// ForwardMsgCtx 转发消息 <1 为成功>
+func (c *Client) ForwardMsgCtx(ctx context.Context, Id uint64, receiver string) (int32, error) {
 	req := genFunReq(Functions_FUNC_FORWARD_MSG)
 	req.Msg = &Request_Fm{
 		Fm: &ForwardMsg{
@@ -365,19 +570,18 @@ func (c *Client) ForwardMsg(Id uint64, receiver string) int32 {
 			Receiver: receiver,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal ForwardMsg err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal ForwardMsg err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // SendIMG 发送图片
 func (c *Client) SendIMG(path string, receiver string) int32 {
+	status, err := c.SendIMGCtx(context.Background(), path, receiver)
+	logErr(err, "internal SendIMG err")
+	return status
+}
+
+// SendIMGCtx 发送图片 <0 为成功>
+func (c *Client) SendIMGCtx(ctx context.Context, path string, receiver string) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_IMG)
 	req.Msg = &Request_File{
 		File: &PathMsg{
@@ -385,19 +589,18 @@ func (c *Client) SendIMG(path string, receiver string) int32 {
 			Receiver: receiver,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendIMG err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendIMG err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 0)
 }
 
 // SendFile 发送文件
 func (c *Client) SendFile(path string, receiver string) int32 {
+	status, err := c.SendFileCtx(context.Background(), path, receiver)
+	logErr(err, "internal SendFile err")
+	return status
+}
+
+// SendFileCtx 发送文件 <0 为成功>
+func (c *Client) SendFileCtx(ctx context.Context, path string, receiver string) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_FILE)
 	req.Msg = &Request_File{
 		File: &PathMsg{
@@ -405,19 +608,18 @@ func (c *Client) SendFile(path string, receiver string) int32 {
 			Receiver: receiver,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendFile err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendFile err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 0)
 }
 
 // SendRichText 发送卡片消息
 func (c *Client) SendRichText(name string, account string, title string, digest string, url string, thumburl string, receiver string) int32 {
+	status, err := c.SendRichTextCtx(context.Background(), name, account, title, digest, url, thumburl, receiver)
+	logErr(err, "internal SendRichText err")
+	return status
+}
+
+// SendRichTextCtx 发送卡片消息 <1 为成功>
+func (c *Client) SendRichTextCtx(ctx context.Context, name string, account string, title string, digest string, url string, thumburl string, receiver string) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_RICH_TXT)
 	req.Msg = &Request_Rt{
 		Rt: &RichText{
@@ -430,19 +632,18 @@ func (c *Client) SendRichText(name string, account string, title string, digest
 			Receiver: receiver,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendRichText err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendRichText err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // SendXml 发送xml数据
 func (c *Client) SendXml(path, content, receiver string, Type int32) int32 {
+	status, err := c.SendXmlCtx(context.Background(), path, content, receiver, Type)
+	logErr(err, "internal SendXml err")
+	return status
+}
+
+// SendXmlCtx 发送xml数据 <0 为成功>
+func (c *Client) SendXmlCtx(ctx context.Context, path, content, receiver string, Type int32) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_XML)
 	req.Msg = &Request_Xml{
 		Xml: &XmlMsg{
@@ -452,20 +653,20 @@ func (c *Client) SendXml(path, content, receiver string, Type int32) int32 {
 			Type:     Type,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendXml err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal SendXml err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 0)
 }
 
 // SendEmotion 发送emoji  发送既崩溃
 // Deprecated
 func (c *Client) SendEmotion(path, receiver string) int32 {
+	status, err := c.SendEmotionCtx(context.Background(), path, receiver)
+	logErr(err, "internal SendEmotion err")
+	return status
+}
+
+// SendEmotionCtx 发送emoji <0 为成功>
+// Deprecated
+func (c *Client) SendEmotionCtx(ctx context.Context, path, receiver string) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_EMOTION)
 	req.Msg = &Request_File{
 		File: &PathMsg{
@@ -473,19 +674,18 @@ func (c *Client) SendEmotion(path, receiver string) int32 {
 			Receiver: receiver,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
-	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 0)
 }
 
 // SendPat 发送拍一拍消息
 func (c *Client) SendPat(roomId, wxId string) int32 {
+	status, err := c.SendPatCtx(context.Background(), roomId, wxId)
+	logErr(err, "internal SendPat err")
+	return status
+}
+
+// SendPatCtx 发送拍一拍消息 <1 为成功>
+func (c *Client) SendPatCtx(ctx context.Context, roomId, wxId string) (int32, error) {
 	req := genFunReq(Functions_FUNC_SEND_PAT_MSG)
 	req.Msg = &Request_Pm{
 		Pm: &PatMsg{
@@ -493,19 +693,34 @@ func (c *Client) SendPat(roomId, wxId string) int32 {
 			Wxid:   wxId,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+	return c.callStatus(ctx, req, 1)
+}
+
+// RevokeMsg 撤回消息 <id 为消息的 MsgSvrID>
+func (c *Client) RevokeMsg(id uint64) int32 {
+	status, err := c.RevokeMsgCtx(context.Background(), id)
+	logErr(err, "internal RevokeMsg err")
+	return status
+}
+
+// RevokeMsgCtx 撤回消息 <1 为成功>
+func (c *Client) RevokeMsgCtx(ctx context.Context, id uint64) (int32, error) {
+	req := genFunReq(Functions_FUNC_REVOKE_MSG)
+	req.Msg = &Request_Ui64{
+		Ui64: id,
 	}
-	return recv.GetStatus()
+	return c.callStatus(ctx, req, 1)
 }
 
 // DownloadAttach 下载附件
 func (c *Client) DownloadAttach(id uint64, thumb, extra string) int32 {
+	status, err := c.DownloadAttachCtx(context.Background(), id, thumb, extra)
+	logErr(err, "internal DownloadAttach err")
+	return status
+}
+
+// DownloadAttachCtx 下载附件 <0 为成功>
+func (c *Client) DownloadAttachCtx(ctx context.Context, id uint64, thumb, extra string) (int32, error) {
 	req := genFunReq(Functions_FUNC_DOWNLOAD_ATTACH)
 	req.Msg = &Request_Att{
 		Att: &AttachMsg{
@@ -514,65 +729,100 @@ func (c *Client) DownloadAttach(id uint64, thumb, extra string) int32 {
 			Extra: extra,
 		},
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+	return c.callStatus(ctx, req, 0)
+}
+
+// ExecOCR 识别图片中的文字 <extra 为图片消息的 extra（加密图片路径）>
+func (c *Client) ExecOCR(extra string) *OcrMsg {
+	ocr, err := c.ExecOCRCtx(context.Background(), extra)
+	logErr(err, "internal ExecOCR err")
+	return ocr
+}
+
+// ExecOCRCtx 识别图片中的文字 <Status 为 0 时识别完成，非 0 表示图片尚未就绪或识别失败>
+func (c *Client) ExecOCRCtx(ctx context.Context, extra string) (*OcrMsg, error) {
+	req := genFunReq(Functions_FUNC_EXEC_OCR)
+	req.Msg = &Request_Str{
+		Str: extra,
 	}
-	recv, err := c.Recv()
+	recv, err := c.call(ctx, req)
 	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+		return nil, err
+	}
+	if err = expect[*Response_Ocr](Functions_FUNC_EXEC_OCR, recv); err != nil {
+		return nil, err
 	}
-	return recv.GetStatus()
+	return recv.GetOcr(), nil
 }
 
 // EnableRecvTxt 开启接收数据
 func (c *Client) EnableRecvTxt() int32 {
+	status, err := c.EnableRecvTxtCtx(context.Background())
+	logErr(err, "internal EnableRecvTxt err")
+	return status
+}
+
+// EnableRecvTxtCtx 开启接收数据 <0 为成功>
+func (c *Client) EnableRecvTxtCtx(ctx context.Context) (int32, error) {
 	req := genFunReq(Functions_FUNC_ENABLE_RECV_TXT)
 	req.Msg = &Request_Flag{
 		Flag: true,
 	}
-	err := c.send(req.build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+	status, err := c.callStatus(ctx, req, 0)
+	if err == nil {
+		c.RecvTxt.Store(true)
 	}
-	c.RecvTxt = true
-	return recv.GetStatus()
+	return status, err
 }
 
 // DisableRecvTxt 关闭接收消息
 func (c *Client) DisableRecvTxt() int32 {
-	err := c.send(genFunReq(Functions_FUNC_DISABLE_RECV_TXT).build())
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
-	}
-	recv, err := c.Recv()
-	if err != nil {
-		logging.ErrorWithErr(err, "internal is_login err")
+	status, err := c.DisableRecvTxtCtx(context.Background())
+	logErr(err, "internal DisableRecvTxt err")
+	return status
+}
+
+// DisableRecvTxtCtx 关闭接收消息 <0 为成功>
+func (c *Client) DisableRecvTxtCtx(ctx context.Context) (int32, error) {
+	status, err := c.callStatus(ctx, genFunReq(Functions_FUNC_DISABLE_RECV_TXT), 0)
+	if err == nil {
+		c.RecvTxt.Store(false)
 	}
-	c.RecvTxt = false
-	return recv.GetStatus()
+	return status, err
 }
 
 type MsgHandler func(msg *WxMsg) error
 
-// OnMSG 接收消息
+// OnMSG 接收消息 <ctx 取消、RecvTxt 关闭、客户端关闭（返回 nil）或 socket 出错时返回>
 func (c *Client) OnMSG(ctx context.Context, f MsgHandler) error {
 	socket, err := pair1.NewSocket()
 	if err != nil {
 		return err
 	}
-	_ = socket.SetOption(mangos.OptionRecvDeadline, 5000)
-	_ = socket.SetOption(mangos.OptionSendDeadline, 5000)
+	_ = socket.SetOption(mangos.OptionRecvDeadline, msgRecvTimeout)
+	_ = socket.SetOption(mangos.OptionSendDeadline, msgRecvTimeout)
 	err = socket.Dial(addPort(c.add))
 	if err != nil {
+		_ = socket.Close()
 		return err
 	}
-	defer socket.Close()
-	for c.RecvTxt {
+	c.sockMu.Lock()
+	if c.closed {
+		c.sockMu.Unlock()
+		_ = socket.Close()
+		return ErrClosed
+	}
+	c.msgSocket = socket
+	c.sockMu.Unlock()
+	defer func() {
+		c.sockMu.Lock()
+		if c.msgSocket == socket {
+			c.msgSocket = nil
+		}
+		c.sockMu.Unlock()
+		_ = socket.Close()
+	}()
+	for c.RecvTxt.Load() {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -581,8 +831,14 @@ func (c *Client) OnMSG(ctx context.Context, f MsgHandler) error {
 		}
 		msg := &Response{}
 		recv, err := socket.Recv()
+		if errors.Is(err, mangos.ErrRecvTimeout) {
+			continue // 暂无消息
+		}
+		if errors.Is(err, mangos.ErrClosed) && c.isClosed() {
+			return nil // 客户端已关闭
+		}
 		if err != nil {
-			return err
+			return wrapErr(err)
 		}
 		_ = proto.Unmarshal(recv, msg)
 		go func() {
@@ -593,7 +849,7 @@ func (c *Client) OnMSG(ctx context.Context, f MsgHandler) error {
 		}()
 
 	}
-	return err
+	return nil
 }
 
 // NewWCF 连接
@@ -622,6 +878,20 @@ func genFunReq(fun Functions) *cmdMSG {
 	}
 }
 
+// nextDeadline 本轮收发的超时时间 <不超过 pollInterval，以便及时响应 ctx 取消>
+func nextDeadline(ctx context.Context) time.Duration {
+	d := pollInterval
+	if deadline, ok := ctx.Deadline(); ok {
+		if remain := time.Until(deadline); remain < d {
+			d = remain
+		}
+	}
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	return d
+}
+
 func addPort(add string) string {
 	parts := strings.Split(add, ":")
 	port, _ := strconv.Atoi(parts[2])
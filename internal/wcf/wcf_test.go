@@ -155,7 +155,7 @@ func TestClient_EnableRecvTxt(t *testing.T) {
 		t.Errorf("EnableRecvTxt() = %v, want 0", status)
 	}
 
-	if !c.RecvTxt {
+	if !c.RecvTxt.Load() {
 		t.Errorf("EnableRecvTxt() RecvTxt not set to true")
 	}
 }
@@ -174,7 +174,7 @@ func TestClient_DisableRecvTxt(t *testing.T) {
 		t.Errorf("DisableRecvTxt() = %v, want 0", status)
 	}
 
-	if c.RecvTxt {
+	if c.RecvTxt.Load() {
 		t.Errorf("DisableRecvTxt() RecvTxt not set to false")
 	}
 }
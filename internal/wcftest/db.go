@@ -0,0 +1,277 @@
+// Package wcftest
+// @Author Clover
+// @Data 2026/10/18 上午11:05:00
+// @Desc 内存表与极简 sql 查询（覆盖 sdk 使用到的 select 语句）
+package wcftest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 与 wcf 返回的 DbField.Type 对应
+const (
+	FieldInt   int32 = 1
+	FieldFloat int32 = 2
+	FieldText  int32 = 3
+	FieldBlob  int32 = 4
+	FieldNull  int32 = 5
+)
+
+var (
+	ErrUnsupportedSQL = errors.New("unsupported sql")
+	ErrNoTable        = errors.New("no such table")
+)
+
+var (
+	selectRe = regexp.MustCompile(`(?is)^\s*select\s+(.+?)\s+from\s+(\w+)(?:\s+where\s+(.+?))?(?:\s+order\s+by\s+(\w+)(?:\s+(asc|desc))?)?(?:\s+limit\s+(\d+))?\s*;?\s*$`)
+	condRe   = regexp.MustCompile(`(?i)(\w+)\s*(=|!=|<>|>=|<=|>|<)\s*('(?:[^']|'')*'|-?\d+(?:\.\d+)?)`)
+	andRe    = regexp.MustCompile(`(?i)^\s*(and\s*)?$`)
+)
+
+type table struct {
+	columns []string
+	rows    [][]*wcf.DbField
+}
+
+type database struct {
+	tables map[string]*table
+}
+
+func newDatabase() *database {
+	return &database{tables: make(map[string]*table)}
+}
+
+// AddTable 在 db 中创建表（已存在时保留原数据）
+func (s *Server) AddTable(db, name string, columns ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.dbs[db]
+	if !ok {
+		d = newDatabase()
+		s.dbs[db] = d
+	}
+	if _, ok = d.tables[strings.ToLower(name)]; !ok {
+		d.tables[strings.ToLower(name)] = &table{columns: columns}
+	}
+}
+
+// Insert 向表中插入一行，values 与建表时的列一一对应
+// 支持 string、[]byte、整数、浮点数与 nil
+func (s *Server) Insert(db, name string, values ...interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(db, name, values...)
+}
+
+// insert 调用方需持有 s.mu
+func (s *Server) insert(db, name string, values ...interface{}) error {
+	d, ok := s.dbs[db]
+	if !ok {
+		return fmt.Errorf("%w: %s.%s", ErrNoTable, db, name)
+	}
+	t, ok := d.tables[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("%w: %s.%s", ErrNoTable, db, name)
+	}
+	if len(values) != len(t.columns) {
+		return fmt.Errorf("insert %s.%s: want %d values, got %d", db, name, len(t.columns), len(values))
+	}
+	row := make([]*wcf.DbField, len(values))
+	for i, v := range values {
+		row[i] = toField(t.columns[i], v)
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+func (d *database) schema() []*wcf.DbTable {
+	names := make([]string, 0, len(d.tables))
+	for name := range d.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tables := make([]*wcf.DbTable, 0, len(names))
+	for _, name := range names {
+		tables = append(tables, &wcf.DbTable{Name: name, Sql: "CREATE TABLE " + name + "(" + strings.Join(d.tables[name].columns, ", ") + ")"})
+	}
+	return tables
+}
+
+type cond struct {
+	column string
+	op     string
+	value  string
+}
+
+// query 支持 SELECT <cols|*> FROM t [WHERE a = 'x' AND b >= 1] [ORDER BY c [ASC|DESC]] [LIMIT n]
+func (d *database) query(sql string) ([]*wcf.DbRow, error) {
+	m := selectRe.FindStringSubmatch(sql)
+	if m == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSQL, sql)
+	}
+	t, ok := d.tables[strings.ToLower(m[2])]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoTable, m[2])
+	}
+	conds, err := parseWhere(m[3])
+	if err != nil {
+		return nil, err
+	}
+
+	var matched [][]*wcf.DbField
+	for _, row := range t.rows {
+		if matchAll(t, row, conds) {
+			matched = append(matched, row)
+		}
+	}
+	if m[4] != "" {
+		idx := t.index(m[4])
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: no column %s", ErrUnsupportedSQL, m[4])
+		}
+		desc := strings.EqualFold(m[5], "desc")
+		sort.SliceStable(matched, func(i, j int) bool {
+			c := compare(string(matched[i][idx].Content), string(matched[j][idx].Content))
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if m[6] != "" {
+		limit, _ := strconv.Atoi(m[6])
+		if limit < len(matched) {
+			matched = matched[:limit]
+		}
+	}
+
+	cols := strings.Split(m[1], ",")
+	rows := make([]*wcf.DbRow, 0, len(matched))
+	for _, row := range matched {
+		out := &wcf.DbRow{}
+		for _, col := range cols {
+			col = strings.TrimSpace(col)
+			if col == "*" {
+				out.Fields = append(out.Fields, row...)
+				continue
+			}
+			idx := t.index(col)
+			if idx < 0 {
+				return nil, fmt.Errorf("%w: no column %s", ErrUnsupportedSQL, col)
+			}
+			out.Fields = append(out.Fields, row[idx])
+		}
+		rows = append(rows, out)
+	}
+	return rows, nil
+}
+
+func (t *table) index(column string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c, column) {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseWhere(where string) ([]cond, error) {
+	if where == "" {
+		return nil, nil
+	}
+	var conds []cond
+	last := 0
+	for _, loc := range condRe.FindAllStringSubmatchIndex(where, -1) {
+		if !andRe.MatchString(where[last:loc[0]]) {
+			return nil, fmt.Errorf("%w: where %s", ErrUnsupportedSQL, where)
+		}
+		value := where[loc[6]:loc[7]]
+		if strings.HasPrefix(value, "'") {
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+		conds = append(conds, cond{column: where[loc[2]:loc[3]], op: where[loc[4]:loc[5]], value: value})
+		last = loc[1]
+	}
+	if len(conds) == 0 || strings.TrimSpace(where[last:]) != "" {
+		return nil, fmt.Errorf("%w: where %s", ErrUnsupportedSQL, where)
+	}
+	return conds, nil
+}
+
+func matchAll(t *table, row []*wcf.DbField, conds []cond) bool {
+	for _, c := range conds {
+		idx := t.index(c.column)
+		if idx < 0 {
+			return false
+		}
+		r := compare(string(row[idx].Content), c.value)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = r == 0
+		case "!=", "<>":
+			ok = r != 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "<":
+			ok = r < 0
+		case "<=":
+			ok = r <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compare 两边均为数字时按数值比较，否则按字符串比较
+func compare(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func toField(column string, v interface{}) *wcf.DbField {
+	f := &wcf.DbField{Column: column}
+	switch val := v.(type) {
+	case nil:
+		f.Type = FieldNull
+	case string:
+		f.Type, f.Content = FieldText, []byte(val)
+	case []byte:
+		f.Type, f.Content = FieldBlob, val
+	case int:
+		f.Type, f.Content = FieldInt, []byte(strconv.FormatInt(int64(val), 10))
+	case int32:
+		f.Type, f.Content = FieldInt, []byte(strconv.FormatInt(int64(val), 10))
+	case int64:
+		f.Type, f.Content = FieldInt, []byte(strconv.FormatInt(val, 10))
+	case uint32:
+		f.Type, f.Content = FieldInt, []byte(strconv.FormatUint(uint64(val), 10))
+	case uint64:
+		f.Type, f.Content = FieldInt, []byte(strconv.FormatUint(val, 10))
+	case float64:
+		f.Type, f.Content = FieldFloat, []byte(strconv.FormatFloat(val, 'f', -1, 64))
+	default:
+		f.Type, f.Content = FieldText, []byte(fmt.Sprint(val))
+	}
+	return f
+}
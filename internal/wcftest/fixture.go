@@ -0,0 +1,115 @@
+// Package wcftest
+// @Author Clover
+// @Data 2026/10/18 上午11:40:00
+// @Desc 常用库表的预置结构与数据填充
+package wcftest
+
+import (
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"google.golang.org/protobuf/proto"
+	"strings"
+)
+
+// 预置表结构（只包含 sdk 会查询到的列）
+var (
+	ContactColumns    = []string{"UserName", "Alias", "DelFlag", "Type", "Remark", "NickName", "PYInitial", "QuanPin", "RemarkPYInitial", "RemarkQuanPin", "SmallHeadImgUrl", "BigHeadImgUrl"}
+	ChatRoomColumns   = []string{"ChatRoomName", "UserNameList", "DisplayNameList", "RoomData", "Reserved2"}
+	HeadImgUrlColumns = []string{"usrName", "smallHeadImgUrl", "bigHeadImgUrl"}
+	MSGColumns        = []string{"localId", "TalkerId", "MsgSvrID", "Type", "SubType", "IsSender", "CreateTime", "StrTalker", "StrContent"}
+	MediaColumns      = []string{"Key", "Reserved0", "Buf"}
+)
+
+// Contact 联系人，AddContact 时同时写入通讯录（GET_CONTACTS）与 MicroMsg.db 的 Contact 表
+type Contact struct {
+	Wxid         string
+	Code         string // 微信号
+	Remark       string
+	NickName     string
+	Country      string
+	Province     string
+	City         string
+	Gender       int32
+	SmallHeadURL string
+	BigHeadURL   string
+}
+
+// RoomMember 群成员 <Name 为群昵称>
+type RoomMember struct {
+	Wxid string
+	Name string
+}
+
+func (s *Server) initSchema() {
+	s.dbs["MicroMsg.db"] = newDatabase()
+	s.dbs["MSG0.db"] = newDatabase()
+	s.dbs["MediaMSG0.db"] = newDatabase()
+	s.dbs["MicroMsg.db"].tables["contact"] = &table{columns: ContactColumns}
+	s.dbs["MicroMsg.db"].tables["chatroom"] = &table{columns: ChatRoomColumns}
+	s.dbs["MicroMsg.db"].tables["contactheadimgurl"] = &table{columns: HeadImgUrlColumns}
+	s.dbs["MSG0.db"].tables["msg"] = &table{columns: MSGColumns}
+	s.dbs["MediaMSG0.db"].tables["media"] = &table{columns: MediaColumns}
+}
+
+// AddContact 添加联系人
+func (s *Server) AddContact(c Contact) {
+	s.mu.Lock()
+	s.contacts = append(s.contacts, &wcf.RpcContact{
+		Wxid:     c.Wxid,
+		Code:     c.Code,
+		Remark:   c.Remark,
+		Name:     c.NickName,
+		Country:  c.Country,
+		Province: c.Province,
+		City:     c.City,
+		Gender:   c.Gender,
+	})
+	s.mu.Unlock()
+	contactType := 3 // 好友
+	if strings.HasSuffix(c.Wxid, "@chatroom") {
+		contactType = 2
+	}
+	_ = s.Insert("MicroMsg.db", "Contact", c.Wxid, c.Code, 0, contactType, c.Remark, c.NickName, "", "", "", "", c.SmallHeadURL, c.BigHeadURL)
+	if c.SmallHeadURL != "" || c.BigHeadURL != "" {
+		_ = s.Insert("MicroMsg.db", "ContactHeadImgUrl", c.Wxid, c.SmallHeadURL, c.BigHeadURL)
+	}
+}
+
+// AddChatRoom 添加群聊（通讯录 + ChatRoom 表，RoomData 为 protobuf 编码的成员列表）
+func (s *Server) AddChatRoom(roomId, name, owner string, members ...RoomMember) {
+	s.AddContact(Contact{Wxid: roomId, NickName: name})
+	rd := &wcf.RoomData{}
+	wxids := make([]string, 0, len(members))
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		rd.Members = append(rd.Members, &wcf.RoomData_RoomMember{Wxid: m.Wxid, Name: m.Name})
+		wxids = append(wxids, m.Wxid)
+		names = append(names, m.Name)
+	}
+	data, _ := proto.Marshal(rd)
+	_ = s.Insert("MicroMsg.db", "ChatRoom", roomId, strings.Join(wxids, "^G"), strings.Join(names, "^G"), data, owner)
+}
+
+// SetRoomMembers 替换已有群聊的成员列表 <群不存在时不做处理>
+func (s *Server) SetRoomMembers(roomId string, members ...RoomMember) {
+	rd := &wcf.RoomData{}
+	wxids := make([]string, 0, len(members))
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		rd.Members = append(rd.Members, &wcf.RoomData_RoomMember{Wxid: m.Wxid, Name: m.Name})
+		wxids = append(wxids, m.Wxid)
+		names = append(names, m.Name)
+	}
+	data, _ := proto.Marshal(rd)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.dbs["MicroMsg.db"].tables["chatroom"]
+	for _, row := range t.rows {
+		if string(row[0].GetContent()) != roomId {
+			continue
+		}
+		row[1] = toField("UserNameList", strings.Join(wxids, "^G"))
+		row[2] = toField("DisplayNameList", strings.Join(names, "^G"))
+		row[3] = toField("RoomData", data)
+	}
+}
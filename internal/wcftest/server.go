@@ -0,0 +1,464 @@
+// Package wcftest
+// @Author Clover
+// @Data 2026/10/18 上午10:30:00
+// @Desc 进程内的伪 WeChatFerry 服务端，用于测试与离线开发
+//
+// 服务端与真实 wcf 一样使用 mangos pair1 + protobuf Request/Response，
+// 命令端口为 port，消息端口为 port+1，可直接被 wcf.NewWCF / NewClient 连接。
+package wcftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"go.nanomsg.org/mangos/v3"
+	"go.nanomsg.org/mangos/v3/protocol"
+	"go.nanomsg.org/mangos/v3/protocol/pair1"
+	_ "go.nanomsg.org/mangos/v3/transport/all"
+	"google.golang.org/protobuf/proto"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FirstSentMsgId 伪服务端记录的第一条发送消息的 MsgSvrID，之后依次递增
+const FirstSentMsgId uint64 = 1000000
+
+// HandlerFunc 自定义某个 Functions 的应答，返回 nil 时回退到默认处理
+type HandlerFunc func(req *wcf.Request) *wcf.Response
+
+// QueryFunc 自定义 sql 查询结果，ok 为 false 时回退到内置的表查询
+type QueryFunc func(db, sql string) (rows []*wcf.DbRow, ok bool)
+
+// DefaultStatus 各发送类接口的默认状态码（与真实 wcf 的成功返回值一致）
+var DefaultStatus = map[wcf.Functions]int32{
+	wcf.Functions_FUNC_SEND_TXT:         0,
+	wcf.Functions_FUNC_SEND_IMG:         0,
+	wcf.Functions_FUNC_SEND_FILE:        0,
+	wcf.Functions_FUNC_SEND_XML:         0,
+	wcf.Functions_FUNC_SEND_EMOTION:     0,
+	wcf.Functions_FUNC_SEND_RICH_TXT:    1,
+	wcf.Functions_FUNC_SEND_PAT_MSG:     1,
+	wcf.Functions_FUNC_FORWARD_MSG:      1,
+	wcf.Functions_FUNC_ENABLE_RECV_TXT:  0,
+	wcf.Functions_FUNC_DISABLE_RECV_TXT: 0,
+	wcf.Functions_FUNC_ACCEPT_FRIEND:    1,
+	wcf.Functions_FUNC_RECV_TRANSFER:    1,
+	wcf.Functions_FUNC_REFRESH_PYQ:      1,
+	wcf.Functions_FUNC_DOWNLOAD_ATTACH:  0,
+	wcf.Functions_FUNC_REVOKE_MSG:       1,
+	wcf.Functions_FUNC_ADD_ROOM_MEMBERS: 1,
+	wcf.Functions_FUNC_DEL_ROOM_MEMBERS: 1,
+	wcf.Functions_FUNC_INV_ROOM_MEMBERS: 1,
+}
+
+// DefaultMsgTypes 默认返回的消息类型表
+var DefaultMsgTypes = map[int32]string{
+	0: "朋友圈消息", 1: "文字", 3: "图片", 34: "语音", 37: "好友确认", 40: "POSSIBLEFRIEND_MSG",
+	42: "名片", 43: "视频", 47: "石头剪刀布 | 表情图片", 48: "位置", 49: "共享实时位置、文件、转账、链接",
+	50: "VOIPMSG", 51: "微信初始化", 52: "VOIPNOTIFY", 53: "VOIPINVITE", 62: "小视频",
+	9999: "SYSNOTICE", 10000: "红包、系统消息", 10002: "撤回消息",
+}
+
+// Server 伪 wcf 服务端
+type Server struct {
+	addr    string
+	cmdSock protocol.Socket
+	msgSock protocol.Socket
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	msgCh   chan *wcf.WxMsg
+
+	mu       sync.RWMutex
+	login    bool
+	recvTxt  bool
+	userInfo *wcf.UserInfo
+	msgTypes map[int32]string
+	contacts []*wcf.RpcContact
+	dbs      map[string]*database
+	statuses map[wcf.Functions]int32
+	handlers map[wcf.Functions]HandlerFunc
+	queryFn  QueryFunc
+	requests []*wcf.Request
+	nextMsg  uint64 // 下一条发送消息的 MsgSvrID
+}
+
+// NewServer 启动伪服务端 <addr 为空时在 127.0.0.1 上自动选择空闲的端口对>
+func NewServer(addr string) (*Server, error) {
+	var err error
+	if addr == "" {
+		addr, err = freeAddr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	cmdAddr, msgAddr, err := splitAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		addr:     cmdAddr,
+		ctx:      ctx,
+		cancel:   cancel,
+		msgCh:    make(chan *wcf.WxMsg, 1024),
+		login:    true,
+		userInfo: &wcf.UserInfo{Wxid: "wxid_wcftest_self", Name: "wcftest", Mobile: "13800000000", Home: "C:/Users/wcftest/Documents/WeChat Files/"},
+		msgTypes: DefaultMsgTypes,
+		dbs:      make(map[string]*database),
+		statuses: make(map[wcf.Functions]int32, len(DefaultStatus)),
+		handlers: make(map[wcf.Functions]HandlerFunc),
+		nextMsg:  FirstSentMsgId,
+	}
+	for fn, status := range DefaultStatus {
+		s.statuses[fn] = status
+	}
+	s.initSchema()
+	s.cmdSock, err = listen(cmdAddr)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("listen cmd %s: %w", cmdAddr, err)
+	}
+	s.msgSock, err = listen(msgAddr)
+	if err != nil {
+		cancel()
+		_ = s.cmdSock.Close()
+		return nil, fmt.Errorf("listen msg %s: %w", msgAddr, err)
+	}
+	s.wg.Add(2)
+	go s.serve()
+	go s.pushLoop()
+	return s, nil
+}
+
+// Addr 命令端口地址，可直接传入 wcf.NewWCF 或设置为 TCP_ADDR
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Close 关闭服务端
+func (s *Server) Close() error {
+	s.cancel()
+	err := errors.Join(s.cmdSock.Close(), s.msgSock.Close())
+	s.wg.Wait()
+	return err
+}
+
+// SetLogin 设置登录状态
+func (s *Server) SetLogin(login bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.login = login
+}
+
+// SetUserInfo 设置登录账号信息（同时决定 GET_SELF_WXID 的返回）
+func (s *Server) SetUserInfo(ui *wcf.UserInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userInfo = ui
+}
+
+// SetMsgTypes 设置消息类型表
+func (s *Server) SetMsgTypes(types map[int32]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgTypes = types
+}
+
+// SetStatus 设置某个接口返回的状态码
+func (s *Server) SetStatus(fn wcf.Functions, status int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[fn] = status
+}
+
+// Handle 自定义某个接口的应答，覆盖默认行为
+func (s *Server) Handle(fn wcf.Functions, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h == nil {
+		delete(s.handlers, fn)
+		return
+	}
+	s.handlers[fn] = h
+}
+
+// HandleQuery 自定义 sql 查询结果
+func (s *Server) HandleQuery(fn QueryFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queryFn = fn
+}
+
+// Requests 已收到的请求（按到达顺序）
+func (s *Server) Requests() []*wcf.Request {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reqs := make([]*wcf.Request, len(s.requests))
+	copy(reqs, s.requests)
+	return reqs
+}
+
+// LastRequest 最后一个调用 fn 的请求
+func (s *Server) LastRequest(fn wcf.Functions) *wcf.Request {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].GetFunc() == fn {
+			return s.requests[i]
+		}
+	}
+	return nil
+}
+
+// RecvTxtEnabled 客户端是否开启了消息接收
+func (s *Server) RecvTxtEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recvTxt
+}
+
+// Push 推送一条消息，开启接收（ENABLE_RECV_TXT）后按顺序投递到消息端口
+func (s *Server) Push(msgs ...*wcf.WxMsg) {
+	for _, msg := range msgs {
+		select {
+		case s.msgCh <- msg:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		data, err := s.cmdSock.Recv()
+		if err != nil {
+			if errors.Is(err, mangos.ErrClosed) || s.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		req := &wcf.Request{}
+		rsp := &wcf.Response{}
+		if err = proto.Unmarshal(data, req); err != nil {
+			rsp.Msg = &wcf.Response_Status{Status: -1}
+		} else {
+			rsp = s.handle(req)
+			rsp.Func = req.GetFunc()
+		}
+		out, err := proto.Marshal(rsp)
+		if err != nil {
+			continue
+		}
+		if err = s.cmdSock.Send(out); err != nil && errors.Is(err, mangos.ErrClosed) {
+			return
+		}
+	}
+}
+
+func (s *Server) pushLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var msg *wcf.WxMsg
+		select {
+		case <-s.ctx.Done():
+			return
+		case msg = <-s.msgCh:
+		}
+		for !s.RecvTxtEnabled() { // 未开启接收时暂存
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+		data, err := proto.Marshal(&wcf.Response{Func: wcf.Functions_FUNC_ENABLE_RECV_TXT, Msg: &wcf.Response_Wxmsg{Wxmsg: msg}})
+		if err != nil {
+			continue
+		}
+		if err = s.msgSock.Send(data); err != nil && errors.Is(err, mangos.ErrClosed) {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(req *wcf.Request) *wcf.Response {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	h := s.handlers[req.GetFunc()]
+	s.mu.Unlock()
+	if h != nil {
+		if rsp := h(req); rsp != nil {
+			return rsp
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req.GetFunc() {
+	case wcf.Functions_FUNC_IS_LOGIN:
+		if s.login {
+			return status(1)
+		}
+		return status(0)
+	case wcf.Functions_FUNC_GET_SELF_WXID:
+		return str(s.userInfo.GetWxid())
+	case wcf.Functions_FUNC_GET_USER_INFO:
+		return &wcf.Response{Msg: &wcf.Response_Ui{Ui: s.userInfo}}
+	case wcf.Functions_FUNC_GET_MSG_TYPES:
+		return &wcf.Response{Msg: &wcf.Response_Types{Types: &wcf.MsgTypes{Types: s.msgTypes}}}
+	case wcf.Functions_FUNC_GET_CONTACTS:
+		return &wcf.Response{Msg: &wcf.Response_Contacts{Contacts: &wcf.RpcContacts{Contacts: s.contacts}}}
+	case wcf.Functions_FUNC_GET_CONTACT_INFO:
+		var found []*wcf.RpcContact
+		for _, ct := range s.contacts {
+			if ct.GetWxid() == req.GetStr() {
+				found = append(found, ct)
+			}
+		}
+		return &wcf.Response{Msg: &wcf.Response_Contacts{Contacts: &wcf.RpcContacts{Contacts: found}}}
+	case wcf.Functions_FUNC_GET_DB_NAMES:
+		names := make([]string, 0, len(s.dbs))
+		for name := range s.dbs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return &wcf.Response{Msg: &wcf.Response_Dbs{Dbs: &wcf.DbNames{Names: names}}}
+	case wcf.Functions_FUNC_GET_DB_TABLES:
+		var tables []*wcf.DbTable
+		if db, ok := s.dbs[req.GetStr()]; ok {
+			tables = db.schema()
+		}
+		return &wcf.Response{Msg: &wcf.Response_Tables{Tables: &wcf.DbTables{Tables: tables}}}
+	case wcf.Functions_FUNC_EXEC_DB_QUERY:
+		return &wcf.Response{Msg: &wcf.Response_Rows{Rows: &wcf.DbRows{Rows: s.query(req.GetQuery().GetDb(), req.GetQuery().GetSql())}}}
+	case wcf.Functions_FUNC_ENABLE_RECV_TXT:
+		s.recvTxt = true
+	case wcf.Functions_FUNC_DISABLE_RECV_TXT:
+		s.recvTxt = false
+	case wcf.Functions_FUNC_DECRYPT_IMAGE:
+		return str(req.GetDec().GetDst())
+	case wcf.Functions_FUNC_GET_AUDIO_MSG:
+		return str(path.Join(req.GetAm().GetDir(), strconv.FormatUint(req.GetAm().GetId(), 10)+".mp3"))
+	case wcf.Functions_FUNC_EXEC_OCR:
+		return &wcf.Response{Msg: &wcf.Response_Ocr{Ocr: &wcf.OcrMsg{Status: 0}}}
+	}
+	if st, ok := s.statuses[req.GetFunc()]; ok {
+		if st == DefaultStatus[req.GetFunc()] {
+			s.recordSent(req)
+		}
+		return status(st)
+	}
+	return status(-1) // 未实现的接口
+}
+
+// recordSent 发送成功的消息与真实微信一样写入 MSG0.db（IsSender = 1），以便按库查找刚发送的消息
+func (s *Server) recordSent(req *wcf.Request) {
+	var (
+		receiver, content string
+		msgType           int
+	)
+	switch req.GetFunc() {
+	case wcf.Functions_FUNC_SEND_TXT:
+		receiver, content, msgType = req.GetTxt().GetReceiver(), req.GetTxt().GetMsg(), 1
+	case wcf.Functions_FUNC_SEND_IMG:
+		receiver, content, msgType = req.GetFile().GetReceiver(), req.GetFile().GetPath(), 3
+	case wcf.Functions_FUNC_SEND_EMOTION:
+		receiver, content, msgType = req.GetFile().GetReceiver(), req.GetFile().GetPath(), 47
+	case wcf.Functions_FUNC_SEND_FILE:
+		receiver, content, msgType = req.GetFile().GetReceiver(), req.GetFile().GetPath(), 49
+		if strings.HasSuffix(strings.ToLower(content), ".mp4") { // mp4 以视频消息展示
+			msgType = 43
+		}
+	case wcf.Functions_FUNC_SEND_XML:
+		receiver, content, msgType = req.GetXml().GetReceiver(), req.GetXml().GetContent(), int(req.GetXml().GetType())
+		if msgType == 0 || msgType == 0x21 { // 小程序等 appmsg 在库中记为 49
+			msgType = 49
+		}
+	case wcf.Functions_FUNC_SEND_RICH_TXT:
+		receiver, content, msgType = req.GetRt().GetReceiver(), req.GetRt().GetTitle(), 49
+	default:
+		return
+	}
+	id := s.nextMsg
+	s.nextMsg++
+	_ = s.insert("MSG0.db", "MSG", int(id), 0, id, msgType, 0, 1, time.Now().Unix(), receiver, content)
+}
+
+func (s *Server) query(db, sql string) []*wcf.DbRow {
+	if s.queryFn != nil {
+		if rows, ok := s.queryFn(db, sql); ok {
+			return rows
+		}
+	}
+	d, ok := s.dbs[db]
+	if !ok {
+		return nil
+	}
+	rows, err := d.query(sql)
+	if err != nil {
+		return nil
+	}
+	return rows
+}
+
+func status(st int32) *wcf.Response {
+	return &wcf.Response{Msg: &wcf.Response_Status{Status: st}}
+}
+
+func str(v string) *wcf.Response {
+	return &wcf.Response{Msg: &wcf.Response_Str{Str: v}}
+}
+
+func listen(addr string) (protocol.Socket, error) {
+	sock, err := pair1.NewSocket()
+	if err != nil {
+		return nil, err
+	}
+	if err = sock.Listen(addr); err != nil {
+		_ = sock.Close()
+		return nil, err
+	}
+	return sock, nil
+}
+
+// splitAddr tcp://host:port -> 命令地址, 消息地址(port+1)
+func splitAddr(addr string) (string, string, error) {
+	idx := strings.LastIndex(addr, ":")
+	if idx < 0 {
+		return "", "", fmt.Errorf("invalid addr: %s", addr)
+	}
+	port, err := strconv.Atoi(addr[idx+1:])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid port in addr %s: %w", addr, err)
+	}
+	return addr, addr[:idx+1] + strconv.Itoa(port+1), nil
+}
+
+// freeAddr 选择一对相邻的空闲端口
+func freeAddr() (string, error) {
+	for i := 0; i < 20; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		_ = l.Close()
+		next, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port+1))
+		if err != nil {
+			continue
+		}
+		_ = next.Close()
+		return "tcp://127.0.0.1:" + strconv.Itoa(port), nil
+	}
+	return "", errors.New("no free port pair")
+}
@@ -0,0 +1,123 @@
+package wcftest
+
+import (
+	"context"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (*Server, *wcf.Client) {
+	t.Helper()
+	srv, err := NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	cli, err := wcf.NewWCF(srv.Addr())
+	if err != nil {
+		_ = srv.Close()
+		t.Fatalf("NewWCF() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cli.Close()
+		_ = srv.Close()
+	})
+	return srv, cli
+}
+
+func TestServer_Query(t *testing.T) {
+	srv, cli := newTestServer(t)
+	srv.AddContact(Contact{Wxid: "wxid_a", NickName: "A"})
+	srv.AddContact(Contact{Wxid: "wxid_b", NickName: "B", BigHeadURL: "https://b/0"})
+	for i, ts := range []int{100, 300, 200} {
+		if err := srv.Insert("MSG0.db", "MSG", i+1, 0, uint64(1000+i), 1, 0, 1, ts, "wxid_a", "hi"); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		db     string
+		sql    string
+		column string
+		want   []string
+	}{
+		{name: "select where", db: "MicroMsg.db", sql: "select * from Contact where UserName = 'wxid_b';", column: "NickName", want: []string{"B"}},
+		{name: "select columns", db: "MicroMsg.db", sql: "SELECT UserName, NickName FROM Contact limit 10;", column: "UserName", want: []string{"wxid_a", "wxid_b"}},
+		{name: "order by desc limit", db: "MSG0.db", sql: "SELECT MsgSvrID FROM MSG WHERE IsSender = 1 AND StrTalker = 'wxid_a' ORDER BY CreateTime DESC LIMIT 1;", column: "MsgSvrID", want: []string{"1001"}},
+		{name: "compare", db: "MSG0.db", sql: "SELECT MsgSvrID FROM MSG WHERE CreateTime >= 200 ORDER BY CreateTime;", column: "MsgSvrID", want: []string{"1002", "1001"}},
+		{name: "no match", db: "MicroMsg.db", sql: "select * from Contact where UserName = 'nobody';", want: nil},
+		{name: "unsupported", db: "MicroMsg.db", sql: "update Contact set NickName = 'x';", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := cli.ExecDBQuery(tt.db, tt.sql)
+			if len(rows) != len(tt.want) {
+				t.Fatalf("ExecDBQuery() got %d rows, want %d", len(rows), len(tt.want))
+			}
+			for i, row := range rows {
+				var got string
+				for _, f := range row.GetFields() {
+					if f.GetColumn() == tt.column {
+						got = string(f.GetContent())
+					}
+				}
+				if got != tt.want[i] {
+					t.Errorf("row %d %s = %q, want %q", i, tt.column, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestServer_ScriptedState(t *testing.T) {
+	srv, cli := newTestServer(t)
+	srv.SetUserInfo(&wcf.UserInfo{Wxid: "wxid_self", Name: "bot"})
+	srv.SetStatus(wcf.Functions_FUNC_SEND_TXT, -2)
+	srv.Handle(wcf.Functions_FUNC_EXEC_OCR, func(req *wcf.Request) *wcf.Response {
+		return &wcf.Response{Msg: &wcf.Response_Ocr{Ocr: &wcf.OcrMsg{Status: 0, Result: "ocr:" + req.GetStr()}}}
+	})
+
+	if got := cli.GetSelfWXID(); got != "wxid_self" {
+		t.Errorf("GetSelfWXID() = %q, want wxid_self", got)
+	}
+	if got := cli.SendTxt("hello", "filehelper", nil); got != -2 {
+		t.Errorf("SendTxt() = %d, want -2", got)
+	}
+	req := srv.LastRequest(wcf.Functions_FUNC_SEND_TXT)
+	if req == nil || req.GetTxt().GetMsg() != "hello" || req.GetTxt().GetReceiver() != "filehelper" {
+		t.Errorf("LastRequest(SEND_TXT) = %v", req)
+	}
+	srv.SetLogin(false)
+	if cli.IsLogin() {
+		t.Errorf("IsLogin() = true after SetLogin(false)")
+	}
+}
+
+func TestServer_Push(t *testing.T) {
+	srv, cli := newTestServer(t)
+	srv.Push(&wcf.WxMsg{Id: 1, Type: 1, Content: "first"}, &wcf.WxMsg{Id: 2, Type: 1, Content: "second"})
+	cli.EnableRecvTxt()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgCh := make(chan *wcf.WxMsg, 2)
+	go func() {
+		_ = cli.OnMSG(ctx, func(msg *wcf.WxMsg) error {
+			msgCh <- msg
+			return nil
+		})
+	}()
+	got := map[uint64]string{}
+	for len(got) < 2 {
+		select {
+		case msg := <-msgCh:
+			got[msg.GetId()] = msg.GetContent()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	if got[1] != "first" || got[2] != "second" {
+		t.Errorf("pushed messages = %v", got)
+	}
+}
@@ -0,0 +1,276 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午10:20:00
+// @Desc 图片、文件、视频发送的统一输入
+package wcf_rpc_sdk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"github.com/Clov614/wcf-rpc-sdk/internal/utils/imgutil"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxMediaSize 网络地址、Reader、字节数据落盘的默认大小上限 <100MB>
+const DefaultMaxMediaSize int64 = 100 << 20
+
+var (
+	ErrMediaTooLarge = errors.New("media exceeds size limit")
+	ErrInvalidMedia  = errors.New("invalid media")
+)
+
+// Media 图片、文件、视频的来源 <由 MediaPath、MediaURL、MediaReader、MediaBytes、MediaFrom 创建>
+//
+// 本地路径直接交给 wcf；其他来源会写入临时文件，发送结束后删除
+type Media interface {
+	// open 返回 wcf 可读取的本地路径 <cleanup 总是非 nil>
+	open(ctx context.Context, limit int64) (path string, cleanup func(), err error)
+	String() string
+}
+
+type pathMedia string
+
+// MediaPath 本地文件 <wcf 所在机器上的绝对路径>
+func MediaPath(path string) Media {
+	return pathMedia(path)
+}
+
+func (p pathMedia) open(context.Context, int64) (string, func(), error) {
+	if p == "" {
+		return "", func() {}, fmt.Errorf("%w: empty path", ErrInvalidMedia)
+	}
+	return string(p), func() {}, nil
+}
+
+func (p pathMedia) String() string {
+	return string(p)
+}
+
+type urlMedia string
+
+// MediaURL 网络地址 <以流的方式下载到临时文件>
+func MediaURL(url string) Media {
+	return urlMedia(url)
+}
+
+func (u urlMedia) open(ctx context.Context, limit int64) (string, func(), error) {
+	resp, err := imgutil.OpenURL(ctx, string(u))
+	if err != nil {
+		return "", func() {}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.ContentLength > limit {
+		return "", func() {}, fmt.Errorf("%w: %s is %d bytes, limit %d", ErrMediaTooLarge, u, resp.ContentLength, limit)
+	}
+	var name string
+	if parsed, err := url.Parse(string(u)); err == nil {
+		name = path.Base(parsed.Path)
+	}
+	return writeTempMedia(resp.Body, name, limit)
+}
+
+func (u urlMedia) String() string {
+	return string(u)
+}
+
+type readerMedia struct {
+	r    io.Reader
+	name string
+}
+
+// MediaReader 读取 r 的全部内容 <name 为文件名，发送文件时对方看到的名称，无扩展名时按内容识别>
+func MediaReader(r io.Reader, name string) Media {
+	return &readerMedia{r: r, name: name}
+}
+
+func (m *readerMedia) open(_ context.Context, limit int64) (string, func(), error) {
+	if m.r == nil {
+		return "", func() {}, fmt.Errorf("%w: nil reader", ErrInvalidMedia)
+	}
+	return writeTempMedia(m.r, m.name, limit)
+}
+
+func (m *readerMedia) String() string {
+	return "reader:" + m.name
+}
+
+type bytesMedia struct {
+	data []byte
+	name string
+}
+
+// MediaBytes 字节数据 <name 同 MediaReader，可为空>
+func MediaBytes(data []byte, name string) Media {
+	return &bytesMedia{data: data, name: name}
+}
+
+func (m *bytesMedia) open(_ context.Context, limit int64) (string, func(), error) {
+	if len(m.data) == 0 {
+		return "", func() {}, fmt.Errorf("%w: empty data", ErrInvalidMedia)
+	}
+	if int64(len(m.data)) > limit {
+		return "", func() {}, fmt.Errorf("%w: %d bytes, limit %d", ErrMediaTooLarge, len(m.data), limit)
+	}
+	return writeTempMedia(bytes.NewReader(m.data), m.name, limit)
+}
+
+func (m *bytesMedia) String() string {
+	return fmt.Sprintf("bytes:%s(%d)", m.name, len(m.data))
+}
+
+// MediaFrom 按字符串自动选择 <http(s) 开头为网络地址，否则为本地路径>
+func MediaFrom(src string) Media {
+	if imgutil.IsURL(src) {
+		return MediaURL(src)
+	}
+	return MediaPath(src)
+}
+
+// mediaExt 确定临时文件的扩展名 <识别出图片时以内容为准，其余优先沿用文件名的扩展名>
+func mediaExt(name string, head []byte) string {
+	ext := filepath.Ext(name)
+	fileType, err := imgutil.DetectFileType(head)
+	if err != nil {
+		return ext
+	}
+	if ext == "" || imgutil.IsImage(fileType) {
+		return imgutil.GetEtxByFileType(fileType)
+	}
+	return ext
+}
+
+// writeTempMedia 将 r 写入独立的临时目录 <文件名保留 name，超过 limit 时删除并返回 ErrMediaTooLarge>
+func writeTempMedia(r io.Reader, name string, limit int64) (string, func(), error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(16)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", func() {}, fmt.Errorf("read media err: %w", err)
+	}
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." || name == `\` {
+		name = "media"
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name)) + mediaExt(name, head)
+
+	dir, err := os.MkdirTemp("", "wcf-media-*")
+	if err != nil {
+		return "", func() {}, fmt.Errorf("create temp dir err: %w", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logging.ErrorWithErr(err, "remove temp media")
+		}
+	}
+	dst := filepath.Join(dir, name)
+	f, err := os.Create(dst)
+	if err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("create temp file err: %w", err)
+	}
+	n, err := io.Copy(f, io.LimitReader(br, limit+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", func() {}, fmt.Errorf("write temp file err: %w", err)
+	}
+	if n > limit {
+		cleanup()
+		return "", func() {}, fmt.Errorf("%w: limit %d bytes", ErrMediaTooLarge, limit)
+	}
+	return dst, cleanup, nil
+}
+
+// SetMaxMediaSize 设置网络地址、Reader、字节数据的大小上限 <n <= 0 时恢复 DefaultMaxMediaSize>
+func (c *Client) SetMaxMediaSize(n int64) {
+	if n <= 0 {
+		n = DefaultMaxMediaSize
+	}
+	c.mediaLimit.Store(n)
+}
+
+func (c *Client) maxMediaSize() int64 {
+	if n := c.mediaLimit.Load(); n > 0 {
+		return n
+	}
+	return DefaultMaxMediaSize
+}
+
+// media 在调用方协程中准备好本地文件，排队执行 send，结束后清理临时文件
+func (o Outbox) media(receiver string, media Media, send func(path string) (*SendResult, error)) *SendFuture {
+	c := o.cli
+	if media == nil {
+		return failedSend(fmt.Errorf("%w: nil media", ErrInvalidMedia))
+	}
+	src, cleanup, err := media.open(c.ctx, c.maxMediaSize())
+	if err != nil {
+		return failedSend(fmt.Errorf("open media %s err: %w", media, err))
+	}
+	return c.enqueueTask(o.priority, &sendTask{
+		receiver: receiver,
+		send:     func() (*SendResult, error) { return send(src) },
+		cleanup:  cleanup,
+	})
+}
+
+// Image 排队发送图片 <参数同 Client.SendImageMedia>
+func (o Outbox) Image(receiver string, media Media) *SendFuture {
+	c := o.cli
+	return o.media(receiver, media, func(path string) (*SendResult, error) {
+		sent := c.newSendResult(receiver, MsgTypeImage)
+		res, err := c.wxClient.SendIMGCtx(c.ctx, path, receiver)
+		if err != nil {
+			logging.Debug("wxCliend.SendIMG", map[string]interface{}{"res": res, "receiver": receiver, "media": media.String(), "path": path})
+			return nil, fmt.Errorf("wxClient.SendIMG err: %w", err)
+		}
+		return sent, nil
+	})
+}
+
+// File 排队发送文件 <参数同 Client.SendFileMedia>
+func (o Outbox) File(receiver string, media Media) *SendFuture {
+	return o.file(receiver, media, MsgTypeXML)
+}
+
+// Video 排队发送视频 <参数同 Client.SendVideo>
+func (o Outbox) Video(receiver string, media Media) *SendFuture {
+	return o.file(receiver, media, MsgTypeVideo)
+}
+
+func (o Outbox) file(receiver string, media Media, msgType MsgType) *SendFuture {
+	c := o.cli
+	return o.media(receiver, media, func(path string) (*SendResult, error) {
+		sent := c.newSendResult(receiver, msgType)
+		res, err := c.wxClient.SendFileCtx(c.ctx, path, receiver)
+		if err != nil {
+			logging.Debug("wxCliend.SendFile", map[string]interface{}{"res": res, "receiver": receiver, "media": media.String(), "path": path})
+			return nil, fmt.Errorf("wxClient.SendFile err: %w", err)
+		}
+		return sent, nil
+	})
+}
+
+// SendImageMedia 发送图片 <wxid or roomid> <图片来源>
+func (c *Client) SendImageMedia(receiver string, media Media) (*SendResult, error) {
+	return c.Outbox(SendPriorityNormal).Image(receiver, media).Wait()
+}
+
+// SendFileMedia 发送文件 <wxid or roomid> <文件来源，对方看到的文件名为 Media 的文件名>
+func (c *Client) SendFileMedia(receiver string, media Media) (*SendResult, error) {
+	return c.Outbox(SendPriorityNormal).File(receiver, media).Wait()
+}
+
+// SendVideo 发送视频 <wxid or roomid> <mp4 视频来源> <微信以视频消息展示通过文件接口发送的 mp4>
+func (c *Client) SendVideo(receiver string, media Media) (*SendResult, error) {
+	return c.Outbox(SendPriorityNormal).Video(receiver, media).Wait()
+}
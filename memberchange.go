@@ -0,0 +1,305 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午5:50:00
+// @Desc 群成员变动事件（加入、退出、移出）
+package wcf_rpc_sdk
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"regexp"
+	"strings"
+)
+
+var ErrNotMemberChange = errors.New("not a member change message")
+
+// MemberChangeKind 成员变动类型
+type MemberChangeKind int
+
+const (
+	MemberJoined  MemberChangeKind = iota + 1 // 加入群聊
+	MemberLeft                                // 退出群聊
+	MemberRemoved                             // 被移出群聊
+)
+
+var memberChangeKindNames = map[MemberChangeKind]string{
+	MemberJoined:  "joined",
+	MemberLeft:    "left",
+	MemberRemoved: "removed",
+}
+
+func (k MemberChangeKind) String() string {
+	if name, ok := memberChangeKindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// JoinMethod 入群方式
+type JoinMethod int
+
+const (
+	JoinUnknown  JoinMethod = iota // 未知 <非加入事件>
+	JoinByInvite                   // 成员邀请
+	JoinByQRCode                   // 扫描群二维码
+)
+
+var joinMethodNames = map[JoinMethod]string{
+	JoinUnknown:  "unknown",
+	JoinByInvite: "invite",
+	JoinByQRCode: "qrcode",
+}
+
+func (m JoinMethod) String() string {
+	if name, ok := joinMethodNames[m]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ChangedMember 变动的成员 <文本形式的系统消息只有昵称，Wxid 通过群成员列表反查，查不到时为空>
+type ChangedMember struct {
+	Wxid     string `json:"wxid,omitempty"`
+	NickName string `json:"nickName,omitempty"`
+}
+
+// MemberChangeEvent 群成员变动事件
+type MemberChangeEvent struct {
+	RoomId     string           `json:"roomId"`
+	Kind       MemberChangeKind `json:"kind"`
+	JoinMethod JoinMethod       `json:"joinMethod"`       // 加入事件的入群方式
+	Actor      *ChangedMember   `json:"actor,omitempty"`  // 邀请者、二维码分享者或移出操作者 <退出事件为空>
+	Members    []ChangedMember  `json:"members"`          // 加入、退出或被移出的成员
+	Text       string           `json:"text"`             // 展示文本
+	IsSelf     bool             `json:"isSelf,omitempty"` // 变动的成员是否包含自己
+}
+
+// Wxids 变动成员的 wxid <未能反查到的成员不包含在内>
+func (e *MemberChangeEvent) Wxids() []string {
+	ids := make([]string, 0, len(e.Members))
+	for _, m := range e.Members {
+		if m.Wxid != "" {
+			ids = append(ids, m.Wxid)
+		}
+	}
+	return ids
+}
+
+// 模板中用 selfToken 代表自己（"你"），$name$ 代表一组成员
+const selfToken = "你"
+
+var (
+	quotedNames     = regexp.MustCompile(`"([^"]*)"`)
+	quotedHolder    = regexp.MustCompile(`"(\$[A-Za-z0-9_]+\$)"`)
+	holderToken     = `(\$[A-Za-z0-9_]+\$|` + selfToken + `)`
+	memberChangeRes = []struct {
+		re     *regexp.Regexp
+		kind   MemberChangeKind
+		method JoinMethod
+		actor  int // 子匹配序号，0 表示无
+		member int
+	}{
+		{regexp.MustCompile(`^` + holderToken + `将` + holderToken + `移出了群聊`), MemberRemoved, JoinUnknown, 1, 2},
+		{regexp.MustCompile(`^` + holderToken + `被` + holderToken + `移出群聊`), MemberRemoved, JoinUnknown, 2, 1},
+		{regexp.MustCompile(`^` + holderToken + `通过扫描` + holderToken + `分享的二维码加入群聊`), MemberJoined, JoinByQRCode, 2, 1},
+		{regexp.MustCompile(`^` + holderToken + `邀请` + holderToken + `加入了群聊`), MemberJoined, JoinByInvite, 1, 2},
+		{regexp.MustCompile(`^` + holderToken + `(?:已)?退出了?群聊`), MemberLeft, JoinUnknown, 0, 1},
+		{regexp.MustCompile(`^` + holderToken + `加入了群聊`), MemberJoined, JoinUnknown, 0, 1},
+	}
+)
+
+// memberTemplate 统一文本形式与 sysmsgtemplate 形式的系统消息
+type memberTemplate struct {
+	template string                     // 占位符形式的模板
+	links    map[string][]ChangedMember // 占位符对应的成员
+	text     string                     // 展示文本
+}
+
+type sysmsgTemplateXML struct {
+	Type     string `xml:"type,attr"`
+	Template struct {
+		Type     string `xml:"type,attr"`
+		Template string `xml:"template"`
+		Links    []struct {
+			Name    string `xml:"name,attr"`
+			Members []struct {
+				UserName string `xml:"username"`
+				NickName string `xml:"nickname"`
+			} `xml:"memberlist>member"`
+			Separator string `xml:"separator"`
+		} `xml:"link_list>link"`
+	} `xml:"sysmsgtemplate>content_template"`
+}
+
+// parseMemberTemplate 解析 <sysmsgtemplate> 形式的系统消息
+func parseMemberTemplate(content string) (*memberTemplate, error) {
+	if idx := strings.Index(content, "<"); idx > 0 { // 群聊中内容可能带有 "roomid:\n" 前缀
+		content = content[idx:]
+	}
+	var sys sysmsgTemplateXML
+	if err := xml.Unmarshal([]byte(content), &sys); err != nil {
+		return nil, fmt.Errorf("unmarshal sysmsgtemplate err: %w", err)
+	}
+	if sys.Type != "sysmsgtemplate" || sys.Template.Template == "" {
+		return nil, ErrNotMemberChange
+	}
+	mt := &memberTemplate{template: sys.Template.Template, links: make(map[string][]ChangedMember)}
+	text := mt.template
+	for _, link := range sys.Template.Links {
+		names := make([]string, 0, len(link.Members))
+		for _, m := range link.Members {
+			mt.links["$"+link.Name+"$"] = append(mt.links["$"+link.Name+"$"], ChangedMember{Wxid: m.UserName, NickName: m.NickName})
+			names = append(names, m.NickName)
+		}
+		sep := link.Separator
+		if sep == "" {
+			sep = "、"
+		}
+		text = strings.ReplaceAll(text, "$"+link.Name+"$", strings.Join(names, sep))
+	}
+	mt.text = text
+	return mt, nil
+}
+
+// parseMemberText 解析文本形式的系统消息，如 "Alice"邀请"Bob、Carol"加入了群聊
+func parseMemberText(content string) *memberTemplate {
+	content = strings.NewReplacer("“", `"`, "”", `"`).Replace(strings.TrimSpace(content))
+	mt := &memberTemplate{links: make(map[string][]ChangedMember), text: content}
+	i := 0
+	mt.template = quotedNames.ReplaceAllStringFunc(content, func(s string) string {
+		i++
+		holder := fmt.Sprintf("$q%d$", i)
+		for _, name := range strings.Split(quotedNames.FindStringSubmatch(s)[1], "、") {
+			mt.links[holder] = append(mt.links[holder], ChangedMember{NickName: name})
+		}
+		return holder
+	})
+	return mt
+}
+
+// toEvent 按模板判断变动类型 <self 为自己的 wxid 与昵称>
+func (mt *memberTemplate) toEvent(roomId string, self ChangedMember) (*MemberChangeEvent, error) {
+	template := quotedHolder.ReplaceAllString(mt.template, "$1")
+	for _, r := range memberChangeRes {
+		match := r.re.FindStringSubmatch(template)
+		if match == nil {
+			continue
+		}
+		e := &MemberChangeEvent{RoomId: roomId, Kind: r.kind, JoinMethod: r.method, Text: mt.text}
+		resolve := func(token string) []ChangedMember {
+			if token == selfToken {
+				return []ChangedMember{self}
+			}
+			return mt.links[token]
+		}
+		if r.actor > 0 {
+			if actors := resolve(match[r.actor]); len(actors) > 0 {
+				actor := actors[0]
+				e.Actor = &actor
+			}
+		}
+		e.Members = append(e.Members, resolve(match[r.member])...)
+		if match[r.member] == selfToken {
+			e.IsSelf = true
+		}
+		return e, nil
+	}
+	return nil, ErrNotMemberChange
+}
+
+// parseMemberChange 解析群成员变动的系统消息 <文本形式或 sysmsgtemplate 形式>
+func parseMemberChange(roomId string, content string, self ChangedMember) (*MemberChangeEvent, error) {
+	var mt *memberTemplate
+	if strings.Contains(content, "<sysmsgtemplate>") {
+		var err error
+		if mt, err = parseMemberTemplate(content); err != nil {
+			return nil, err
+		}
+	} else {
+		mt = parseMemberText(content)
+	}
+	return mt.toEvent(roomId, self)
+}
+
+// fillMemberChange 解析群成员变动事件，清除群成员缓存并反查成员 wxid
+func (c *Client) fillMemberChange(m *Message) {
+	if m.RoomId == "" {
+		return
+	}
+	selfInfo, _ := c.GetSelfInfo()
+	event, err := parseMemberChange(m.RoomId, m.Content, ChangedMember{Wxid: selfInfo.Wxid, NickName: selfInfo.Name})
+	if err != nil {
+		return // 其他系统消息
+	}
+	// 成员列表可能尚未更新，解析完成后再清除缓存，避免将旧列表重新写入缓存
+	defer c.cacheMember.InvalidateRoom(m.RoomId)
+	var known []*ContactInfo // 变动前后的成员，用于按昵称反查 wxid
+	if m.RoomData != nil {
+		known = append(known, m.RoomData.Members...)
+	}
+	lookup := func(cm *ChangedMember) {
+		if cm.Wxid != "" {
+			return
+		}
+		for _, info := range known {
+			if info != nil && (info.Alias == cm.NickName || info.NickName == cm.NickName) {
+				cm.Wxid = info.Wxid
+				return
+			}
+		}
+	}
+	resolveAll := func() (resolved bool) {
+		resolved = true
+		if event.Actor != nil {
+			lookup(event.Actor)
+		}
+		for i := range event.Members {
+			lookup(&event.Members[i])
+			resolved = resolved && event.Members[i].Wxid != ""
+		}
+		return resolved
+	}
+	if !resolveAll() && event.Kind == MemberJoined {
+		known = append(known, c.joinedMembers(m.RoomId, known)...)
+		resolveAll()
+	}
+	for i := range event.Members {
+		if event.Members[i].Wxid == selfInfo.Wxid {
+			event.IsSelf = true
+		}
+	}
+	m.MemberChange = event
+}
+
+// joinedMembers 新入群的成员 <只查询 RoomData 中不在 known 里的成员的昵称，不逐个查询已有成员；known 为空时只返回群昵称>
+func (c *Client) joinedMembers(roomId string, known []*ContactInfo) []*ContactInfo {
+	rd, err := c.queryRoomData(c.ctx, roomId)
+	if err != nil {
+		logging.Debug("query room data for joined members", map[string]interface{}{"roomId": roomId, "err": err.Error()})
+		return nil
+	}
+	old := make(map[string]bool, len(known))
+	for _, info := range known {
+		if info != nil {
+			old[info.Wxid] = true
+		}
+	}
+	var joined []*ContactInfo
+	for _, member := range rd.GetMembers() {
+		if old[member.GetWxid()] {
+			continue
+		}
+		cp := ContactInfo{Wxid: member.GetWxid(), Alias: member.GetName()}
+		if len(old) == 0 {
+			joined = append(joined, &cp)
+			continue
+		}
+		if info, err := c.GetMemberCtx(c.ctx, member.GetWxid(), true); err == nil {
+			cp.NickName = info.NickName
+		}
+		joined = append(joined, &cp)
+	}
+	return joined
+}
@@ -6,6 +6,7 @@ package wcf_rpc_sdk
 
 import (
 	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"github.com/Clov614/logging"
@@ -20,9 +21,14 @@ var (
 )
 
 type IMeta interface {
-	ReplyText(content string, ats ...string) error
-	ReplyImage(src string) error
-	ReplyFile(src string) error
+	ReplyText(content string, ats ...string) (*SendResult, error)
+	ReplyLongText(content string, ats ...string) ([]*SendResult, error)
+	ReplyImage(media Media) (*SendResult, error)
+	ReplyFile(media Media) (*SendResult, error)
+	ReplyQuote(text string) (*SendResult, error)
+	RevokeMsg(id uint64) error
+	SendPat(roomId string, wxid string) error
+	ReceiveTransfer(payer string, transferId string, transactionId string) error
 	IsSendByFriend() bool
 	AcceptNewFriend(req NewFriendReq) bool
 }
@@ -36,18 +42,44 @@ type meta struct {
 }
 
 // ReplyText 回复文本
-func (m *meta) ReplyText(content string, ats ...string) error {
-	return m.cli.SendText(m.sender, content, ats...)
+func (m *meta) ReplyText(content string, ats ...string) (*SendResult, error) {
+	return m.cli.Outbox(SendPriorityHigh).Text(m.sender, content, ats...).Wait()
+}
+
+// ReplyLongText 回复文本，超长时切分为多条
+func (m *meta) ReplyLongText(content string, ats ...string) ([]*SendResult, error) {
+	return waitAll(m.cli.Outbox(SendPriorityHigh).LongText(m.sender, content, ats...))
 }
 
 // ReplyImage 回复图片
-func (m *meta) ReplyImage(src string) error {
-	return m.cli.SendImage(m.sender, src)
+func (m *meta) ReplyImage(media Media) (*SendResult, error) {
+	return m.cli.Outbox(SendPriorityHigh).Image(m.sender, media).Wait()
 }
 
 // ReplyFile 回复文件
-func (m *meta) ReplyFile(src string) error {
-	return m.cli.SendFile(m.sender, src)
+func (m *meta) ReplyFile(media Media) (*SendResult, error) {
+	return m.cli.Outbox(SendPriorityHigh).File(m.sender, media).Wait()
+}
+
+// ReplyQuote 引用回复
+func (m *meta) ReplyQuote(text string) (*SendResult, error) {
+	return m.cli.Outbox(SendPriorityHigh).Quote(m.sender, text, m.rawMsg).Wait()
+}
+
+// RevokeMsg 撤回消息
+func (m *meta) RevokeMsg(id uint64) error {
+	return m.cli.RevokeMsg(id)
+}
+
+// SendPat 拍一拍
+func (m *meta) SendPat(roomId string, wxid string) error {
+	_, err := m.cli.Outbox(SendPriorityHigh).Pat(roomId, wxid).Wait()
+	return err
+}
+
+// ReceiveTransfer 收款
+func (m *meta) ReceiveTransfer(payer string, transferId string, transactionId string) error {
+	return m.cli.ReceiveTransfer(payer, transferId, transactionId)
 }
 
 // AcceptNewFriend 通过好友请求
@@ -64,25 +96,35 @@ func (m *meta) IsSendByFriend() bool {
 }
 
 type Message struct {
-	meta         IMeta         // 用于实现对客户端操作
-	IsSelf       bool          `json:"is_self,omitempty"`
-	IsGroup      bool          `json:"is_group,omitempty"`
-	IsGH         bool          `json:"is_gh,omitempty"` // 是否公众号
-	MessageId    uint64        `json:"message_id,omitempty"`
-	Type         MsgType       `json:"type,omitempty"`
-	Ts           uint32        `json:"ts,omitempty"`
-	RoomId       string        `json:"room_id,omitempty"`
-	RoomData     *RoomData     `json:"room_data,omitempty"`
-	Content      string        `json:"content,omitempty"`
-	WxId         string        `json:"wx_id,omitempty"`
-	Sign         string        `json:"sign,omitempty"`
-	Thumb        string        `json:"thumb,omitempty"`
-	Extra        string        `json:"extra,omitempty"`
-	Xml          string        `json:"xml,omitempty"`
-	FileInfo     *FileInfo     `json:"file_info,omitempty"`      // 图片保存信息
-	Quote        *QuoteMsg     `json:"quote,omitempty"`          // 引用消息
-	Forward      *ForwardMsg   `json:"forward,omitempty"`        // 转发消息
-	NewFriendReq *NewFriendReq `json:"new_friend_req,omitempty"` // 新好友请求
+	meta         IMeta              // 用于实现对客户端操作
+	IsSelf       bool               `json:"is_self,omitempty"`
+	IsGroup      bool               `json:"is_group,omitempty"`
+	IsGH         bool               `json:"is_gh,omitempty"` // 是否公众号
+	MessageId    uint64             `json:"message_id,omitempty"`
+	Type         MsgType            `json:"type,omitempty"`
+	Ts           uint32             `json:"ts,omitempty"`
+	RoomId       string             `json:"room_id,omitempty"`
+	RoomData     *RoomData          `json:"room_data,omitempty"`
+	Content      string             `json:"content,omitempty"`
+	WxId         string             `json:"wx_id,omitempty"`
+	Sign         string             `json:"sign,omitempty"`
+	Thumb        string             `json:"thumb,omitempty"`
+	Extra        string             `json:"extra,omitempty"`
+	Xml          string             `json:"xml,omitempty"`
+	FileInfo     *FileInfo          `json:"file_info,omitempty"`      // 图片保存信息
+	Quote        *QuoteMsg          `json:"quote,omitempty"`          // 引用消息
+	Forward      *ForwardMsg        `json:"forward,omitempty"`        // 转发消息
+	Link         *LinkMsg           `json:"link,omitempty"`           // 链接消息
+	MiniProgram  *MiniProgram       `json:"mini_program,omitempty"`   // 小程序消息
+	Music        *MusicCard         `json:"music,omitempty"`          // 音乐消息
+	NewFriendReq *NewFriendReq      `json:"new_friend_req,omitempty"` // 新好友请求
+	Location     *Location          `json:"location,omitempty"`       // 位置消息
+	ContactCard  *ContactCard       `json:"contact_card,omitempty"`   // 名片消息
+	RevokeEvent  *RevokeEvent       `json:"revoke_event,omitempty"`   // 撤回事件
+	PatEvent     *PatEvent          `json:"pat_event,omitempty"`      // 拍一拍事件
+	MemberChange *MemberChangeEvent `json:"member_change,omitempty"`  // 群成员变动事件
+	Transfer     *Transfer          `json:"transfer,omitempty"`       // 转账消息
+	AppMsg       *AppMsg            `json:"app_msg,omitempty"`        // appmsg 公共字段与子类型内容 <49 类型消息>
 
 	//UserInfo *UserInfo `json:"user_info,omitempty"` todo
 	//Contacts *Contacts `json:"contact,omitempty"`
@@ -101,7 +143,9 @@ type FileInfo struct {
 	FileName                   string `json:"file_name,omitempty"`                      // File name including extension
 	FileExt                    string `json:"file_ext,omitempty"`                       // File extension
 	IsImg                      bool   `json:"is_img,omitempty"`                         // Indicates if the file is an image
-	Data                       []byte `json:"-"	`                                       // 图片数据
+	Data                       []byte `json:"-"`                                        // 图片数据
+
+	cli *Client // 用于 OCR 等需要调用客户端的操作
 }
 
 // DecryptImg 解析图片信息
@@ -136,18 +180,26 @@ func (fi *FileInfo) ExtractRelativePath() string {
 }
 
 // ReplyText 回复文本
-func (m *Message) ReplyText(content string, ats ...string) error {
+func (m *Message) ReplyText(content string, ats ...string) (*SendResult, error) {
 	return m.meta.ReplyText(content, ats...)
 }
 
-// ReplyImage 回复图片
-func (m *Message) ReplyImage(src string) error {
-	return m.meta.ReplyImage(src)
+// ReplyImage 回复图片 <如 MediaFrom("https://...")、MediaBytes(data, "")>
+func (m *Message) ReplyImage(media Media) (*SendResult, error) {
+	return m.meta.ReplyImage(media)
 }
 
-// ReplyFile 回复文件
-func (m *Message) ReplyFile(src string) error {
-	return m.meta.ReplyFile(src)
+// ReplyFile 回复文件 <如 MediaPath("C:/a.pdf")、MediaReader(r, "报表.xlsx")>
+func (m *Message) ReplyFile(media Media) (*SendResult, error) {
+	return m.meta.ReplyFile(media)
+}
+
+// Revoke 撤回该消息 <只能撤回自己发送的、两分钟内的消息>
+func (m *Message) Revoke() error {
+	if !m.IsSelf {
+		return ErrNotSelfMsg
+	}
+	return m.meta.RevokeMsg(m.MessageId)
 }
 
 // IsSendByFriend 是否为好友的消息
@@ -384,6 +436,13 @@ const (
 	MsgTypeXMLImage          MsgType = 4903    // XML 中的图片消息
 	MsgTypeXMLFile           MsgType = 4906    // XML 中的文件消息
 	MsgTypeXMLLink           MsgType = 4916    // XML 中的链接消息
+	MsgTypeXMLVideo          MsgType = 4904    // XML 中的视频消息 <appmsg type 4>
+	MsgTypeXMLMiniProgram    MsgType = 4933    // XML 中的小程序消息 <appmsg type 33/36>
+	MsgTypeXMLChannelVideo   MsgType = 4951    // XML 中的视频号视频 <appmsg type 51>
+	MsgTypeXMLMusic          MsgType = 4976    // XML 中的音乐消息 <appmsg type 3/76>
+	MsgTypeXMLAnnouncement   MsgType = 4987    // XML 中的群公告 <appmsg type 87>
+	MsgTypeXMLTransfer       MsgType = 492000  // XML 中的转账消息 <appmsg type 2000>
+	MsgTypeXMLRedPacket      MsgType = 492001  // XML 中的红包消息 <appmsg type 2001>
 	MsgTypeVoip              MsgType = 50      // VOIPMSG
 	MsgTypeWechatInit        MsgType = 51      // 微信初始化
 	MsgTypeVoipNotify        MsgType = 52      // VOIPNOTIFY
@@ -425,6 +484,13 @@ var MsgTypeNames = map[MsgType]string{
 	MsgTypeXMLImage:          "XML图片",
 	MsgTypeXMLFile:           "XML文件",
 	MsgTypeXMLLink:           "XML链接",
+	MsgTypeXMLVideo:          "XML视频",
+	MsgTypeXMLMiniProgram:    "XML小程序",
+	MsgTypeXMLChannelVideo:   "XML视频号视频",
+	MsgTypeXMLMusic:          "XML音乐",
+	MsgTypeXMLAnnouncement:   "XML群公告",
+	MsgTypeXMLTransfer:       "XML转账",
+	MsgTypeXMLRedPacket:      "XML红包",
 	MsgTypeVoip:              "VOIPMSG",
 	MsgTypeWechatInit:        "微信初始化",
 	MsgTypeVoipNotify:        "VOIPNOTIFY",
@@ -476,6 +542,62 @@ type FileMsg struct {
 	} `xml:"appattach"`
 }
 
+// Location 位置消息 <x 为纬度，y 为经度>
+type Location struct {
+	Latitude  float64 `xml:"x,attr" json:"latitude"`
+	Longitude float64 `xml:"y,attr" json:"longitude"`
+	Scale     int     `xml:"scale,attr" json:"scale"`     // 地图缩放级别
+	Label     string  `xml:"label,attr" json:"label"`     // 详细地址
+	PoiName   string  `xml:"poiname,attr" json:"poiName"` // 地点名称
+	PoiId     string  `xml:"poiid,attr" json:"poiId"`     // 地点 id
+}
+
+// parseLocation 解析位置消息 XML
+func parseLocation(xmlStr string) (*Location, error) {
+	var msg struct {
+		Location *Location `xml:"location"`
+	}
+	if err := xml.Unmarshal([]byte(trimAppMsg(xmlStr)), &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal location err: %w", err)
+	}
+	if msg.Location == nil {
+		return nil, errors.New("location node not found")
+	}
+	return msg.Location, nil
+}
+
+// ContactCard 名片消息 <个人名片或公众号名片>
+type ContactCard struct {
+	XMLName      xml.Name `xml:"msg" json:"-"`
+	UserName     string   `xml:"username,attr" json:"userName"`            // 微信ID
+	NickName     string   `xml:"nickname,attr" json:"nickName"`            // 昵称
+	Alias        string   `xml:"alias,attr" json:"alias"`                  // 微信号
+	Province     string   `xml:"province,attr" json:"province"`            // 省份
+	City         string   `xml:"city,attr" json:"city"`                    // 城市
+	Sex          int      `xml:"sex,attr" json:"sex"`                      // 性别 <0 未知 1 男 2 女>
+	BigHeadURL   string   `xml:"bigheadimgurl,attr" json:"bigHeadUrl"`     // 大头像
+	SmallHeadURL string   `xml:"smallheadimgurl,attr" json:"smallHeadUrl"` // 小头像
+	CertFlag     int      `xml:"certflag,attr" json:"certFlag"`            // 认证标记 <公众号名片非 0>
+	CertInfo     string   `xml:"certinfo,attr" json:"certInfo"`            // 认证信息
+}
+
+// IsGH 是否为公众号名片
+func (cc *ContactCard) IsGH() bool {
+	return cc.CertFlag != 0 || strings.HasPrefix(cc.UserName, "gh_")
+}
+
+// parseContactCard 解析名片消息 XML
+func parseContactCard(xmlStr string) (*ContactCard, error) {
+	card := &ContactCard{}
+	if err := xml.Unmarshal([]byte(trimAppMsg(xmlStr)), card); err != nil {
+		return nil, fmt.Errorf("unmarshal contact card err: %w", err)
+	}
+	if card.UserName == "" {
+		return nil, errors.New("contact card without username")
+	}
+	return card, nil
+}
+
 type SpecialUserType int
 
 const (
@@ -1,7 +1,13 @@
 package wcf_rpc_sdk
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFileInfo_ExtractRelativePath(t *testing.T) {
@@ -52,3 +58,692 @@ func TestFileInfo_ExtractRelativePath(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected *Location
+		wantErr  bool
+	}{
+		{
+			name: "Location with poi",
+			content: `<?xml version="1.0"?>
+<msg>
+	<location x="22.543096" y="114.057865" scale="15" label="广东省深圳市福田区福中三路" maptype="roadmap" poiname="深圳市民中心" poiid="qqmap_4385917234127868211" buildingId="" floorName="" poiCategoryTips="" poiBusinessHour="" poiPhone="" poiPriceTips="" isFromPoiList="true" adcode="440304" cityname="深圳市" fromusername="wxid_pagpb98c6nj722" />
+</msg>`,
+			expected: &Location{Latitude: 22.543096, Longitude: 114.057865, Scale: 15, Label: "广东省深圳市福田区福中三路", PoiName: "深圳市民中心", PoiId: "qqmap_4385917234127868211"},
+		},
+		{
+			name:     "Location without poi",
+			content:  `<msg><location x="39.908692" y="116.397477" scale="16" label="北京市东城区" maptype="0" poiname="[位置]" /></msg>`,
+			expected: &Location{Latitude: 39.908692, Longitude: 116.397477, Scale: 16, Label: "北京市东城区", PoiName: "[位置]"},
+		},
+		{
+			name:     "Group location",
+			content:  "45959390469@chatroom:\n" + `<msg><location x="39.908692" y="116.397477" scale="16" label="北京市东城区" maptype="0" poiname="[位置]" /></msg>`,
+			expected: &Location{Latitude: 39.908692, Longitude: 116.397477, Scale: 16, Label: "北京市东城区", PoiName: "[位置]"},
+		},
+		{
+			name:    "Missing location node",
+			content: `<msg><appmsg><title>not a location</title></appmsg></msg>`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid xml",
+			content: `<msg><location x="22.5"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLocation(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseLocation() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseContactCard(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected *ContactCard
+		isGH     bool
+		wantErr  bool
+	}{
+		{
+			name: "Personal card",
+			content: `<?xml version="1.0"?>
+<msg bigheadimgurl="http://wx.qlogo.cn/mmhead/ver_1/big/0" smallheadimgurl="http://wx.qlogo.cn/mmhead/ver_1/small/132" username="wxid_jj4mhsji9tjk22" nickname="Bob" fullpy="bob" shortpy="" alias="bob_2025" imagestatus="3" scene="17" province="广东" city="深圳" sign="" sex="1" certflag="0" certinfo="" brandIconUrl="" brandHomeUrl="" brandSubscriptConfigUrl="" brandFlags="0" regionCode="CN_Guangdong_Shenzhen" biznamecardinfo="" antispamticket="wxid_jj4mhsji9tjk22" />`,
+			expected: &ContactCard{
+				UserName: "wxid_jj4mhsji9tjk22", NickName: "Bob", Alias: "bob_2025", Province: "广东", City: "深圳", Sex: 1,
+				BigHeadURL: "http://wx.qlogo.cn/mmhead/ver_1/big/0", SmallHeadURL: "http://wx.qlogo.cn/mmhead/ver_1/small/132",
+			},
+		},
+		{
+			name: "Official account card",
+			content: `<?xml version="1.0"?>
+<msg bigheadimgurl="http://wx.qlogo.cn/mmhead/Q3auHgzwzM/0" smallheadimgurl="http://wx.qlogo.cn/mmhead/Q3auHgzwzM/132" username="gh_3dfda90e39d6" nickname="微信支付" fullpy="weixinzhifu" shortpy="WXZF" alias="wxpay" imagestatus="0" scene="17" province="广东" city="中国" sign="" sex="0" certflag="24" certinfo="微信支付官方账号" brandIconUrl="" brandHomeUrl="" brandSubscriptConfigUrl="" brandFlags="0" regionCode="CN_Guangdong_Shenzhen" />`,
+			expected: &ContactCard{
+				UserName: "gh_3dfda90e39d6", NickName: "微信支付", Alias: "wxpay", Province: "广东", City: "中国",
+				BigHeadURL: "http://wx.qlogo.cn/mmhead/Q3auHgzwzM/0", SmallHeadURL: "http://wx.qlogo.cn/mmhead/Q3auHgzwzM/132",
+				CertFlag: 24, CertInfo: "微信支付官方账号",
+			},
+			isGH: true,
+		},
+		{
+			name:     "Group card",
+			content:  "45959390469@chatroom:\n" + `<msg username="wxid_jj4mhsji9tjk22" nickname="Bob" alias="bob_2025" />`,
+			expected: &ContactCard{UserName: "wxid_jj4mhsji9tjk22", NickName: "Bob", Alias: "bob_2025"},
+		},
+		{
+			name:    "Missing username",
+			content: `<msg nickname="nobody" />`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseContactCard(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseContactCard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got.XMLName = tt.expected.XMLName
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseContactCard() got = %+v, want %+v", got, tt.expected)
+			}
+			if got.IsGH() != tt.isGH {
+				t.Errorf("IsGH() = %v, want %v", got.IsGH(), tt.isGH)
+			}
+		})
+	}
+}
+
+func TestParseRevokeEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected *RevokeEvent
+		wantErr  bool
+	}{
+		{
+			name:     "Private revoke",
+			content:  `<sysmsg type="revokemsg"><revokemsg><session>wxid_pagpb98c6nj722</session><msgid>1057020960</msgid><newmsgid>7862452235853946623</newmsgid><replacemsg><![CDATA["Alice" 撤回了一条消息]]></replacemsg></revokemsg></sysmsg>`,
+			expected: &RevokeEvent{Session: "wxid_pagpb98c6nj722", MsgId: 1057020960, NewMsgId: 7862452235853946623, ReplaceMsg: `"Alice" 撤回了一条消息`},
+		},
+		{
+			name: "Group revoke with sender prefix",
+			content: `wxid_pagpb98c6nj722:
+<sysmsg type="revokemsg"><revokemsg><session>45959390469@chatroom</session><msgid>1057020961</msgid><newmsgid>1453098371254712012</newmsgid><replacemsg><![CDATA["Alice" 撤回了一条消息]]></replacemsg></revokemsg></sysmsg>`,
+			expected: &RevokeEvent{Session: "45959390469@chatroom", MsgId: 1057020961, NewMsgId: 1453098371254712012, ReplaceMsg: `"Alice" 撤回了一条消息`},
+		},
+		{
+			name:    "Other sysmsg",
+			content: `<sysmsg type="pat"><pat><fromusername>wxid_a</fromusername></pat></sysmsg>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRevokeEvent(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRevokeEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseRevokeEvent() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMemoryMessageStore(t *testing.T) {
+	s := NewMemoryMessageStore(2, 50*time.Millisecond)
+	for id := uint64(1); id <= 3; id++ {
+		s.Put(&Message{MessageId: id})
+	}
+	if _, ok := s.Get(1); ok || s.Len() != 2 {
+		t.Errorf("oldest message not evicted, len = %d", s.Len())
+	}
+	if m, ok := s.Get(3); !ok || m.MessageId != 3 {
+		t.Errorf("Get(3) = %v, %v", m, ok)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if _, ok := s.Get(3); ok || s.Len() != 0 {
+		t.Errorf("expired message still returned, len = %d", s.Len())
+	}
+}
+
+func TestParsePatEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected *PatEvent
+		wantErr  bool
+	}{
+		{
+			name: "Sysmsg pat",
+			content: `<sysmsg type="pat">
+<pat>
+  <fromusername>wxid_pagpb98c6nj722</fromusername>
+  <chatusername>45959390469@chatroom</chatusername>
+  <pattedusername>wxid_wcftest_self</pattedusername>
+  <patsuffix><![CDATA[]]></patsuffix>
+  <patsuffixversion>0</patsuffixversion>
+  <template><![CDATA["${wxid_pagpb98c6nj722}" 拍了拍 "${wxid_wcftest_self}"]]></template>
+</pat>
+</sysmsg>`,
+			expected: &PatEvent{FromUser: "wxid_pagpb98c6nj722", PattedUser: "wxid_wcftest_self", ChatUser: "45959390469@chatroom",
+				Template: `"${wxid_pagpb98c6nj722}" 拍了拍 "${wxid_wcftest_self}"`, RawTemplate: `"${wxid_pagpb98c6nj722}" 拍了拍 "${wxid_wcftest_self}"`},
+		},
+		{
+			name: "Appmsg pat records",
+			content: `<msg><appmsg appid="" sdkver="0"><title><![CDATA["Bob" 拍了拍我]]></title><type>62</type>
+<patMsg><chatUser>wxid_jj4mhsji9tjk22</chatUser><records><recordNum>2</recordNum>
+<record><fromUser>wxid_wcftest_self</fromUser><pattedUser>wxid_jj4mhsji9tjk22</pattedUser><templete><![CDATA[我拍了拍 "${wxid_jj4mhsji9tjk22}"]]></templete></record>
+<record><fromUser>wxid_jj4mhsji9tjk22</fromUser><pattedUser>wxid_wcftest_self</pattedUser><templete><![CDATA["${wxid_jj4mhsji9tjk22}" 拍了拍我]]></templete></record>
+</records></patMsg></appmsg></msg>`,
+			expected: &PatEvent{FromUser: "wxid_jj4mhsji9tjk22", PattedUser: "wxid_wcftest_self", ChatUser: "wxid_jj4mhsji9tjk22",
+				Template: `"${wxid_jj4mhsji9tjk22}" 拍了拍我`, RawTemplate: `"${wxid_jj4mhsji9tjk22}" 拍了拍我`},
+		},
+		{
+			name:    "Revoke sysmsg",
+			content: `<sysmsg type="revokemsg"><revokemsg><newmsgid>1</newmsgid></revokemsg></sysmsg>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePatEvent(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePatEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parsePatEvent() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTransfer(t *testing.T) {
+	const transferXML = `<msg>
+<appmsg appid="" sdkver="">
+<title><![CDATA[微信转账]]></title>
+<des><![CDATA[收到转账0.01元。如需收钱，请点此升级至最新版本]]></des>
+<type>2000</type>
+<wcpayinfo>
+<paysubtype>%d</paysubtype>
+<feedesc><![CDATA[￥0.01]]></feedesc>
+<transcationid><![CDATA[53010000012345202410180123456789]]></transcationid>
+<transferid><![CDATA[1000050001202410180112345678901]]></transferid>
+<invalidtime><![CDATA[1729324800]]></invalidtime>
+<begintransfertime><![CDATA[1729238400]]></begintransfertime>
+<effectivedate><![CDATA[1]]></effectivedate>
+<pay_memo><![CDATA[午饭]]></pay_memo>
+<receiver_username><![CDATA[wxid_wcftest_self]]></receiver_username>
+<payer_username><![CDATA[wxid_pagpb98c6nj722]]></payer_username>
+</wcpayinfo>
+</appmsg>
+</msg>`
+	want := func(state TransferState) *Transfer {
+		return &Transfer{Amount: 0.01, FeeDesc: "￥0.01", Memo: "午饭", Payer: "wxid_pagpb98c6nj722", Receiver: "wxid_wcftest_self",
+			State: state, TransferId: "1000050001202410180112345678901", TransactionId: "53010000012345202410180123456789",
+			BeginTime: time.Unix(1729238400, 0), ExpireTime: time.Unix(1729324800, 0)}
+	}
+	tests := []struct {
+		name     string
+		content  string
+		expected *Transfer
+		wantErr  bool
+	}{
+		{name: "Pending", content: fmt.Sprintf(transferXML, 1), expected: want(TransferSent)},
+		{name: "Received", content: fmt.Sprintf(transferXML, 3), expected: want(TransferReceived)},
+		{name: "Refunded", content: fmt.Sprintf(transferXML, 4), expected: want(TransferRefunded)},
+		{
+			name:    "Not transfer",
+			content: `<msg><appmsg><title>file</title><type>6</type></appmsg></msg>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTransfer(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTransfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseTransfer() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAppMsg(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantType    MsgType
+		wantApp     AppMsgType
+		wantPayload interface{}
+	}{
+		{
+			name:        "Link",
+			content:     `<msg><appmsg appid="" sdkver="0"><title>标题</title><des>描述</des><type>5</type><url>https://mp.weixin.qq.com/s/abc</url></appmsg></msg>`,
+			wantType:    MsgTypeXMLLink,
+			wantApp:     AppMsgTypeLink,
+			wantPayload: &LinkMsg{Title: "标题", Des: "描述", URL: "https://mp.weixin.qq.com/s/abc"},
+		},
+		{
+			name:        "Music new",
+			content:     `<msg><appmsg appid="wx5aa333606550dfd5" sdkver="0"><title>晴天</title><des>周杰伦</des><type>76</type></appmsg></msg>`,
+			wantType:    MsgTypeXMLMusic,
+			wantApp:     AppMsgTypeMusicNew,
+			wantPayload: &MusicCard{Title: "晴天", Singer: "周杰伦", AppID: "wx5aa333606550dfd5"},
+		},
+		{
+			name:     "File",
+			content:  `<?xml version="1.0"?><msg><appmsg appid="" sdkver="0"><title>report.pdf</title><type>6</type><appattach><totallen>1024</totallen><fileext>pdf</fileext></appattach></appmsg></msg>`,
+			wantType: MsgTypeXMLFile,
+			wantApp:  AppMsgTypeFile,
+			wantPayload: func() *FileMsg {
+				f := &FileMsg{Title: "report.pdf", FileExt: "pdf"}
+				f.AppAttach.TotalLen = "1024"
+				return f
+			}(),
+		},
+		{
+			name:        "Channel video",
+			content:     `<msg><appmsg appid="" sdkver="0"><title>当前微信版本不支持展示该内容，请升级至最新版本。</title><type>51</type><finderFeed><objectId>14375093828386720</objectId><feedType>4</feedType><nickname>新闻频道</nickname><avatar>https://wx.qlogo.cn/a.jpg</avatar><desc>今日要闻</desc><mediaCount>1</mediaCount><objectNonceId>1234_0_0_0</objectNonceId><mediaList><media><mediaType>4</mediaType><url>https://finder.video.qq.com/v.mp4</url><thumbUrl>https://finder.video.qq.com/t.jpg</thumbUrl><videoPlayDuration>30</videoPlayDuration></media></mediaList></finderFeed></appmsg></msg>`,
+			wantType:    MsgTypeXMLChannelVideo,
+			wantApp:     AppMsgTypeChannelVideo,
+			wantPayload: &ChannelVideo{ObjectId: "14375093828386720", ObjectNonceId: "1234_0_0_0", FeedType: 4, NickName: "新闻频道", Avatar: "https://wx.qlogo.cn/a.jpg", Desc: "今日要闻", Media: []ChannelVideoMedia{{MediaType: 4, URL: "https://finder.video.qq.com/v.mp4", ThumbURL: "https://finder.video.qq.com/t.jpg", Duration: 30}}},
+		},
+		{
+			name:        "Announcement",
+			content:     `<msg><appmsg appid="" sdkver="0"><title>群公告</title><type>87</type><textannouncement><![CDATA[周五晚八点开会]]></textannouncement></appmsg></msg>`,
+			wantType:    MsgTypeXMLAnnouncement,
+			wantApp:     AppMsgTypeAnnouncement,
+			wantPayload: &Announcement{Text: "周五晚八点开会"},
+		},
+		{
+			name:        "Red packet",
+			content:     `<msg><appmsg appid="" sdkver=""><title><![CDATA[微信红包]]></title><type>2001</type><wcpayinfo><templateid><![CDATA[7a2a165d31da7fce6dd77e05c300028a]]></templateid><receivertitle><![CDATA[恭喜发财，大吉大利]]></receivertitle><scenetext><![CDATA[微信红包]]></scenetext><nativeurl><![CDATA[wxpay://c2cbizmessagehandler/hongbao/receivehongbao?msgtype=1]]></nativeurl><paymsgid><![CDATA[1000039901202410186001234567890]]></paymsgid></wcpayinfo></appmsg></msg>`,
+			wantType:    MsgTypeXMLRedPacket,
+			wantApp:     AppMsgTypeRedPacket,
+			wantPayload: &RedPacket{Wish: "恭喜发财，大吉大利", SceneText: "微信红包", NativeURL: "wxpay://c2cbizmessagehandler/hongbao/receivehongbao?msgtype=1", PayMsgId: "1000039901202410186001234567890"},
+		},
+		{
+			name:     "Unknown sub-type",
+			content:  `<msg><appmsg appid="" sdkver="0"><title>未知</title><type>9999</type></appmsg></msg>`,
+			wantType: MsgTypeXML,
+			wantApp:  9999,
+		},
+	}
+
+	cli := &Client{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{Type: MsgTypeXML, Content: tt.content}
+			cli.fillAppMsg(m)
+			if m.AppMsg == nil || m.AppMsg.Type != tt.wantApp {
+				t.Fatalf("fillAppMsg() AppMsg = %+v, want type %d", m.AppMsg, tt.wantApp)
+			}
+			if m.Type != tt.wantType {
+				t.Errorf("fillAppMsg() Type = %d, want %d", m.Type, tt.wantType)
+			}
+			if !reflect.DeepEqual(m.AppMsg.Payload, tt.wantPayload) {
+				t.Errorf("fillAppMsg() Payload = %+v, want %+v", m.AppMsg.Payload, tt.wantPayload)
+			}
+		})
+	}
+}
+
+func TestParseLinkMsg(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		expected *LinkMsg
+		wantErr  bool
+	}{
+		{
+			name:    "Official account article",
+			fixture: "appmsg_link_article.xml",
+			expected: &LinkMsg{
+				Title:             "Go 1.23 发布：迭代器与新的标准库包",
+				Des:               "range-over-func 正式可用，unique、iter 包加入标准库",
+				URL:               "http://mp.weixin.qq.com/s?__biz=MzAxMTA4Njc0OQ==&mid=2651456789&idx=1&sn=3f1c2a9e0d&chksm=80bb1234&scene=0#rd",
+				ThumbURL:          "https://mmbiz.qpic.cn/mmbiz_jpg/abc123/0?wx_fmt=jpeg",
+				SourceUsername:    "gh_4a5f2c8e1b3d",
+				SourceDisplayName: "Go语言中文网",
+			},
+		},
+		{
+			name:    "App share with CDATA",
+			fixture: "appmsg_link_share.xml",
+			expected: &LinkMsg{
+				Title: "深圳今日天气：多云转晴 22~29℃",
+				Des:   "点击查看未来 7 天天气预报 & 生活指数",
+				URL:   "https://weather.example.com/city/101280601?from=wx&share=1",
+			},
+		},
+		{
+			name:    "Not a link",
+			fixture: "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `<msg><appmsg><title>report.pdf</title><type>6</type></appmsg></msg>`
+			if tt.fixture != "" {
+				data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+				if err != nil {
+					t.Fatalf("read fixture: %v", err)
+				}
+				content = string(data)
+			}
+			got, err := parseLinkMsg(content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLinkMsg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseLinkMsg() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMiniProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected *MiniProgram
+		wantErr  bool
+	}{
+		{
+			name: "Mini program 33",
+			content: `<?xml version="1.0"?>
+<msg>
+	<appmsg appid="" sdkver="0">
+		<title>点击查看今日菜单</title>
+		<des />
+		<type>33</type>
+		<url>https://mp.weixin.qq.com/mp/waerrpage?appid=wx1234567890abcdef&amp;type=upgrade&amp;upgradetype=3#wechat_redirect</url>
+		<sourceusername>gh_0a1b2c3d4e5f@app</sourceusername>
+		<sourcedisplayname>食堂助手</sourcedisplayname>
+		<appattach>
+			<cdnthumburl>3057020100044b30490201000204a5b2c3d402033d11fe</cdnthumburl>
+			<cdnthumbaeskey>0f1e2d3c4b5a69788796a5b4c3d2e1f0</cdnthumbaeskey>
+		</appattach>
+		<weappinfo>
+			<pagepath><![CDATA[pages/menu/index.html?day=5]]></pagepath>
+			<username>gh_0a1b2c3d4e5f@app</username>
+			<appid>wx1234567890abcdef</appid>
+			<version>58</version>
+			<type>2</type>
+			<weappiconurl><![CDATA[http://mmbiz.qpic.cn/mmbiz_png/icon/640?wx_fmt=png]]></weappiconurl>
+			<shareId><![CDATA[1_wx1234567890abcdef_29ab_1729238400_0]]></shareId>
+			<appservicetype>0</appservicetype>
+		</weappinfo>
+	</appmsg>
+	<fromusername>wxid_pagpb98c6nj722</fromusername>
+	<scene>0</scene>
+</msg>`,
+			expected: &MiniProgram{Title: "点击查看今日菜单", AppId: "wx1234567890abcdef", UserName: "gh_0a1b2c3d4e5f@app",
+				PagePath: "pages/menu/index.html?day=5", DisplayName: "食堂助手", IconURL: "http://mmbiz.qpic.cn/mmbiz_png/icon/640?wx_fmt=png", Version: 58},
+		},
+		{
+			name: "Mini program 36",
+			content: `<msg><appmsg appid="" sdkver="0"><title>活动报名</title><type>36</type><url>https://example.com/activity</url>
+<sourcedisplayname>报名工具</sourcedisplayname><weappinfo><pagepath>pages/signup/index.html</pagepath><username>gh_9f8e7d6c5b4a@app</username><appid>wxfedcba0987654321</appid><type>2</type></weappinfo></appmsg></msg>`,
+			expected: &MiniProgram{Title: "活动报名", AppId: "wxfedcba0987654321", UserName: "gh_9f8e7d6c5b4a@app",
+				PagePath: "pages/signup/index.html", DisplayName: "报名工具"},
+		},
+		{
+			name:    "Link",
+			content: `<msg><appmsg><title>标题</title><type>5</type><url>https://example.com</url></appmsg></msg>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMiniProgram(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMiniProgram() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseMiniProgram() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMusicCard(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected *MusicCard
+		wantErr  bool
+	}{
+		{
+			name: "Music 3",
+			content: `<msg><appmsg appid="wx5aa333606550dfd5" sdkver="0"><title>晴天</title><des>周杰伦</des><action>view</action><type>3</type>
+<url>https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV</url><lowurl></lowurl><dataurl>http://isure.stream.qqmusic.qq.com/C400.m4a?guid=1&amp;vkey=2</dataurl><lowdataurl></lowdataurl>
+<thumburl>https://y.gtimg.cn/music/photo_new/T002R300x300M000.jpg</thumburl></appmsg><fromusername>wxid_pagpb98c6nj722</fromusername>
+<appinfo><version>29</version><appname>QQ音乐</appname></appinfo></msg>`,
+			expected: &MusicCard{Title: "晴天", Singer: "周杰伦", URL: "https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV",
+				DataURL: "http://isure.stream.qqmusic.qq.com/C400.m4a?guid=1&vkey=2", ThumbURL: "https://y.gtimg.cn/music/photo_new/T002R300x300M000.jpg", AppID: "wx5aa333606550dfd5"},
+		},
+		{
+			name: "Music 76",
+			content: `<msg><appmsg appid="wx8dd6ecd81906fd84" sdkver="0"><title>稻香</title><des></des><type>76</type>
+<url>https://music.163.com/song?id=185811</url><lowurl>https://music.163.com/song?id=185811</lowurl><dataurl></dataurl><lowdataurl>http://music.163.com/song/media/outer/url?id=185811</lowdataurl>
+<songalbumurl>https://p1.music.126.net/cover.jpg</songalbumurl><musicShareItem><mvSingerName>周杰伦</mvSingerName><musicDuration>223000</musicDuration></musicShareItem></appmsg></msg>`,
+			expected: &MusicCard{Title: "稻香", Singer: "周杰伦", URL: "https://music.163.com/song?id=185811",
+				DataURL: "http://music.163.com/song/media/outer/url?id=185811", ThumbURL: "https://p1.music.126.net/cover.jpg", AppID: "wx8dd6ecd81906fd84"},
+		},
+		{
+			name:    "Link",
+			content: `<msg><appmsg><title>标题</title><type>5</type><url>https://example.com</url></appmsg></msg>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMusicCard(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMusicCard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseMusicCard() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseMemberChange(t *testing.T) {
+	const room = "45959390469@chatroom"
+	self := ChangedMember{Wxid: "wxid_wcftest_self", NickName: "wcftest"}
+	tests := []struct {
+		name     string
+		content  string
+		expected *MemberChangeEvent
+	}{
+		{
+			name:    "Invite text",
+			content: `"Alice"邀请"Bob、Carol"加入了群聊`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberJoined, JoinMethod: JoinByInvite, Actor: &ChangedMember{NickName: "Alice"},
+				Members: []ChangedMember{{NickName: "Bob"}, {NickName: "Carol"}}, Text: `"Alice"邀请"Bob、Carol"加入了群聊`},
+		},
+		{
+			name:    "Self invite text",
+			content: `你邀请"Bob"加入了群聊  `,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberJoined, JoinMethod: JoinByInvite, Actor: &self,
+				Members: []ChangedMember{{NickName: "Bob"}}, Text: `你邀请"Bob"加入了群聊`},
+		},
+		{
+			name:    "Invited self text",
+			content: `"Alice"邀请你加入了群聊，群聊参与人还有：Bob`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberJoined, JoinMethod: JoinByInvite, Actor: &ChangedMember{NickName: "Alice"},
+				Members: []ChangedMember{self}, Text: `"Alice"邀请你加入了群聊，群聊参与人还有：Bob`, IsSelf: true},
+		},
+		{
+			name:    "QR code text",
+			content: `“Bob”通过扫描“Alice”分享的二维码加入群聊`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberJoined, JoinMethod: JoinByQRCode, Actor: &ChangedMember{NickName: "Alice"},
+				Members: []ChangedMember{{NickName: "Bob"}}, Text: `"Bob"通过扫描"Alice"分享的二维码加入群聊`},
+		},
+		{
+			name:    "Kick text",
+			content: `你将"Bob"移出了群聊`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberRemoved, Actor: &self,
+				Members: []ChangedMember{{NickName: "Bob"}}, Text: `你将"Bob"移出了群聊`},
+		},
+		{
+			name:    "Kicked self text",
+			content: `你被"Alice"移出群聊`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberRemoved, Actor: &ChangedMember{NickName: "Alice"},
+				Members: []ChangedMember{self}, Text: `你被"Alice"移出群聊`, IsSelf: true},
+		},
+		{
+			name: "Invite sysmsgtemplate",
+			content: `<sysmsg type="sysmsgtemplate">
+	<sysmsgtemplate>
+		<content_template type="tmpl_type_profile">
+			<plain><![CDATA[]]></plain>
+			<template><![CDATA["$username$"邀请"$names$"加入了群聊]]></template>
+			<link_list>
+				<link name="username" type="link_profile">
+					<memberlist>
+						<member><username><![CDATA[wxid_pagpb98c6nj722]]></username><nickname><![CDATA[Alice]]></nickname></member>
+					</memberlist>
+				</link>
+				<link name="names" type="link_profile">
+					<memberlist>
+						<member><username><![CDATA[wxid_jj4mhsji9tjk22]]></username><nickname><![CDATA[Bob]]></nickname></member>
+						<member><username><![CDATA[wxid_carol]]></username><nickname><![CDATA[Carol]]></nickname></member>
+					</memberlist>
+					<separator><![CDATA[、]]></separator>
+				</link>
+			</link_list>
+		</content_template>
+	</sysmsgtemplate>
+</sysmsg>`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberJoined, JoinMethod: JoinByInvite, Actor: &ChangedMember{Wxid: "wxid_pagpb98c6nj722", NickName: "Alice"},
+				Members: []ChangedMember{{Wxid: "wxid_jj4mhsji9tjk22", NickName: "Bob"}, {Wxid: "wxid_carol", NickName: "Carol"}}, Text: `"Alice"邀请"Bob、Carol"加入了群聊`},
+		},
+		{
+			name: "QR code sysmsgtemplate",
+			content: `<sysmsg type="sysmsgtemplate"><sysmsgtemplate><content_template type="tmpl_type_profile"><plain><![CDATA[]]></plain>
+<template><![CDATA["$adder$"通过扫描你分享的二维码加入群聊]]></template>
+<link_list><link name="adder" type="link_profile"><memberlist><member><username><![CDATA[wxid_carol]]></username><nickname><![CDATA[Carol]]></nickname></member></memberlist></link></link_list>
+</content_template></sysmsgtemplate></sysmsg>`,
+			expected: &MemberChangeEvent{RoomId: room, Kind: MemberJoined, JoinMethod: JoinByQRCode, Actor: &self,
+				Members: []ChangedMember{{Wxid: "wxid_carol", NickName: "Carol"}}, Text: `"Carol"通过扫描你分享的二维码加入群聊`},
+		},
+		{
+			name:    "Other system text",
+			content: `"Alice"修改群名为"测试13"`,
+		},
+		{
+			name:    "Red packet text",
+			content: `你领取了"Alice"的红包`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMemberChange(room, tt.content, self)
+			if tt.expected == nil {
+				if !errors.Is(err, ErrNotMemberChange) {
+					t.Fatalf("parseMemberChange() = %+v, %v, want ErrNotMemberChange", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMemberChange() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseMemberChange() got = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		limit    int
+		keep     []textSpan
+		expected []string
+	}{
+		{
+			name:     "Short",
+			text:     "你好",
+			limit:    10,
+			expected: []string{"你好"},
+		},
+		{
+			name:     "Paragraph",
+			text:     "第一段内容。\n\n第二段内容比较长一些。",
+			limit:    12,
+			expected: []string{"第一段内容。", "第二段内容比较长一些。"},
+		},
+		{
+			name:     "Sentence",
+			text:     "今天天气很好。我们去公园吧！好的",
+			limit:    10,
+			expected: []string{"今天天气很好。", "我们去公园吧！好的"},
+		},
+		{
+			name:     "Decimal",
+			text:     "The price is 3.5 dollars. Buy now",
+			limit:    20,
+			expected: []string{"The price is 3.5", "dollars. Buy now"},
+		},
+		{
+			name:     "Mention",
+			text:     "前缀文字@群昵称很长\u2005后面",
+			limit:    8,
+			keep:     []textSpan{{start: 4, end: 11}},
+			expected: []string{"前缀文字", "@群昵称很长", "后面"},
+		},
+		{
+			name:     "LongMention",
+			text:     "@一个非常非常长的群昵称\u2005后面",
+			limit:    8,
+			keep:     []textSpan{{start: 0, end: 13}},
+			expected: []string{"@一个非常非常长", "的群昵称\u2005后面"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitText(tt.text, tt.limit, tt.keep)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("splitText() got = %q, want %q", got, tt.expected)
+			}
+			for i, part := range got {
+				if n := len([]rune(part)); n > tt.limit {
+					t.Errorf("part %d has %d runes, want <= %d", i, n, tt.limit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午8:20:00
+// @Desc 小程序消息解析与发送
+package wcf_rpc_sdk
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/appmsg"
+	"strings"
+)
+
+var (
+	ErrNotMiniProgram     = errors.New("not a mini program message")
+	ErrInvalidMiniProgram = errors.New("invalid mini program")
+)
+
+// SendXml 中 appmsg 卡片（小程序、引用等）的 xml 类型
+const xmlTypeAppMsg = 0x21
+
+// MiniProgram 小程序消息 <appmsg type 33/36 的 weappinfo>
+type MiniProgram struct {
+	Title       string `json:"title"`
+	AppId       string `json:"appId"`                 // 小程序 appid，如 wx1234567890abcdef
+	UserName    string `json:"userName"`              // 小程序原始 id，如 gh_xxx@app
+	PagePath    string `json:"pagePath,omitempty"`    // 页面路径，如 pages/index/index.html?id=1
+	DisplayName string `json:"displayName,omitempty"` // 小程序名称
+	IconURL     string `json:"iconUrl,omitempty"`     // 小程序图标
+	ThumbURL    string `json:"thumbUrl,omitempty"`    // 封面 <收到的消息封面通常在 cdn 上，此时为空>
+	Version     int    `json:"version,omitempty"`
+}
+
+// parseMiniProgram 解析小程序消息
+func parseMiniProgram(content string) (*MiniProgram, error) {
+	msg, err := appmsg.Parse(trimAppMsg(content))
+	if err != nil {
+		return nil, err
+	}
+	app, info := msg.AppMsg, msg.AppMsg.WeAppInfo
+	if (app.Type != AppMsgTypeMiniProgram && app.Type != AppMsgTypeMiniProgramApp) || info == nil || info.AppId == "" {
+		return nil, ErrNotMiniProgram
+	}
+	return &MiniProgram{
+		Title:       strings.TrimSpace(app.Title),
+		AppId:       info.AppId,
+		UserName:    info.UserName,
+		PagePath:    info.PagePath,
+		DisplayName: app.SourceDisplayName,
+		IconURL:     info.IconURL,
+		ThumbURL:    app.ThumbURL,
+		Version:     info.Version,
+	}, nil
+}
+
+func decodeMiniProgram(content string) (MsgType, interface{}, error) {
+	mp, err := parseMiniProgram(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return MsgTypeXMLMiniProgram, mp, nil
+}
+
+// normalizePagePath 微信卡片中的页面路径带 .html 后缀，如 pages/index/index.html?id=1
+func normalizePagePath(path string) string {
+	page, query, hasQuery := strings.Cut(path, "?")
+	if page == "" || strings.HasSuffix(page, ".html") {
+		return path
+	}
+	page += ".html"
+	if hasQuery {
+		return page + "?" + query
+	}
+	return page
+}
+
+// miniProgramMsg 生成小程序卡片
+func miniProgramMsg(mp MiniProgram) (*appmsg.Msg, error) {
+	if mp.AppId == "" || mp.UserName == "" || mp.Title == "" {
+		return nil, fmt.Errorf("%w: appId, userName and title are required", ErrInvalidMiniProgram)
+	}
+	return appmsg.New(AppMsgTypeMiniProgram).
+		Title(mp.Title).
+		URL("https://mp.weixin.qq.com/mp/waerrpage?appid="+mp.AppId+"&type=upgrade&upgradetype=3#wechat_redirect").
+		Source(mp.UserName, mp.DisplayName).
+		ThumbURL(mp.ThumbURL).
+		WeAppInfo(appmsg.WeAppInfo{
+			UserName: mp.UserName,
+			AppId:    mp.AppId,
+			Type:     2, // 2 为小程序
+			Version:  mp.Version,
+			IconURL:  mp.IconURL,
+			PagePath: normalizePagePath(mp.PagePath),
+		}).
+		Msg(), nil
+}
+
+// SendMiniProgram 发送小程序卡片 <wxid or roomid> <AppId、UserName、Title 必填>
+// 封面只能通过 ThumbURL 引用网络图片，sdk 无法上传 cdn 封面，部分微信版本会显示默认封面
+func (c *Client) SendMiniProgram(receiver string, mp MiniProgram) (*SendResult, error) {
+	msg, err := miniProgramMsg(mp)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAppMsg(receiver, msg)
+}
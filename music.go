@@ -0,0 +1,115 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午9:20:00
+// @Desc 音乐卡片解析与发送
+package wcf_rpc_sdk
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/appmsg"
+	"strings"
+)
+
+var (
+	ErrNotMusic     = errors.New("not a music message")
+	ErrInvalidMusic = errors.New("invalid music card")
+)
+
+// MusicCard 音乐卡片 <appmsg type 3/76>
+type MusicCard struct {
+	Title    string `json:"title"`
+	Singer   string `json:"singer,omitempty"`
+	URL      string `json:"url,omitempty"`      // 点击卡片打开的页面
+	DataURL  string `json:"dataUrl,omitempty"`  // 音频地址
+	ThumbURL string `json:"thumbUrl,omitempty"` // 封面
+	AppID    string `json:"appId,omitempty"`    // 来源应用，如 QQ音乐 wx5aa333606550dfd5 <为空时卡片不显示来源>
+}
+
+// musicXML 音乐消息 <新版（type 76）的歌手在 musicShareItem 中>
+type musicXML struct {
+	AppMsg struct {
+		AppId        string     `xml:"appid,attr"`
+		Title        string     `xml:"title"`
+		Des          string     `xml:"des"`
+		Type         AppMsgType `xml:"type"`
+		URL          string     `xml:"url"`
+		LowURL       string     `xml:"lowurl"`
+		DataURL      string     `xml:"dataurl"`
+		LowDataURL   string     `xml:"lowdataurl"`
+		ThumbURL     string     `xml:"thumburl"`
+		SongAlbumURL string     `xml:"songalbumurl"`
+		ShareItem    *struct {
+			SingerName string `xml:"mvSingerName"`
+		} `xml:"musicShareItem"`
+	} `xml:"appmsg"`
+}
+
+// parseMusicCard 解析音乐消息 <appmsg type 3/76，以及 MsgTypeMusicLink>
+func parseMusicCard(content string) (*MusicCard, error) {
+	var msg musicXML
+	if err := xml.Unmarshal([]byte(trimAppMsg(content)), &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal music err: %w", err)
+	}
+	app := msg.AppMsg
+	if app.Type != AppMsgTypeMusic && app.Type != AppMsgTypeMusicNew {
+		return nil, ErrNotMusic
+	}
+	music := &MusicCard{
+		Title:    strings.TrimSpace(app.Title),
+		Singer:   strings.TrimSpace(app.Des),
+		URL:      app.URL,
+		DataURL:  app.DataURL,
+		ThumbURL: app.ThumbURL,
+		AppID:    app.AppId,
+	}
+	if music.Singer == "" && app.ShareItem != nil {
+		music.Singer = app.ShareItem.SingerName
+	}
+	if music.URL == "" {
+		music.URL = app.LowURL
+	}
+	if music.DataURL == "" {
+		music.DataURL = app.LowDataURL
+	}
+	if music.ThumbURL == "" {
+		music.ThumbURL = app.SongAlbumURL
+	}
+	return music, nil
+}
+
+func decodeMusicCard(content string) (MsgType, interface{}, error) {
+	music, err := parseMusicCard(content)
+	if err != nil {
+		return 0, nil, err
+	}
+	return MsgTypeXMLMusic, music, nil
+}
+
+// musicMsg 生成音乐卡片
+func musicMsg(music MusicCard) (*appmsg.Msg, error) {
+	if music.Title == "" || music.DataURL == "" {
+		return nil, fmt.Errorf("%w: title and dataUrl are required", ErrInvalidMusic)
+	}
+	msg := appmsg.New(AppMsgTypeMusic).
+		AppId(music.AppID).
+		Title(music.Title).
+		Des(music.Singer).
+		Action("view").
+		URL(music.URL).
+		DataURL(music.DataURL).
+		ThumbURL(music.ThumbURL).
+		Msg()
+	msg.AppMsg.LowURL = music.URL
+	return msg, nil
+}
+
+// SendMusic 发送音乐卡片 <wxid or roomid> <Title、DataURL 必填>
+func (c *Client) SendMusic(receiver string, music MusicCard) (*SendResult, error) {
+	msg, err := musicMsg(music)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAppMsg(receiver, msg)
+}
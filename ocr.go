@@ -0,0 +1,60 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午3:20:00
+// @Desc 图片文字识别（OCR）
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"time"
+)
+
+var (
+	ErrOCRNotReady = errors.New("ocr not ready") // 等待超时，图片仍未下载完成或无法识别
+	ErrNoClient    = errors.New("no client bound to the file info")
+)
+
+const (
+	ocrTimeout       = 30 * time.Second       // OCR 的默认等待时间
+	ocrRetryInterval = 500 * time.Millisecond // 图片未就绪时的重试间隔
+)
+
+// OCR 识别图片中的文字 <extra 为图片消息的 Extra（加密图片路径）> <最多等待 30 秒>
+func (c *Client) OCR(extra string) (string, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, ocrTimeout)
+	defer cancel()
+	return c.OCRCtx(ctx, extra)
+}
+
+// OCRCtx 识别图片中的文字 <图片未下载完成时重试，直到识别完成或 ctx 结束>
+func (c *Client) OCRCtx(ctx context.Context, extra string) (string, error) {
+	for {
+		ocr, err := c.wxClient.ExecOCRCtx(ctx, extra)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", fmt.Errorf("%w: %w", ErrOCRNotReady, err)
+			}
+			return "", fmt.Errorf("wxClient.ExecOCR err: %w", err)
+		}
+		if ocr.GetStatus() == 0 {
+			return ocr.GetResult(), nil
+		}
+		logging.Debug("ocr not ready, retrying", map[string]interface{}{"extra": extra, "status": ocr.GetStatus()})
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w: status %d", ErrOCRNotReady, ocr.GetStatus())
+		case <-time.After(ocrRetryInterval):
+		}
+	}
+}
+
+// OCR 识别图片中的文字 <仅对收到的图片消息有效>
+func (fi *FileInfo) OCR() (string, error) {
+	if fi.cli == nil {
+		return "", ErrNoClient
+	}
+	return fi.cli.OCR(fi.FilePath)
+}
@@ -0,0 +1,188 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午5:20:00
+// @Desc 拍一拍
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"regexp"
+	"strings"
+)
+
+var ErrNotPat = errors.New("not a pat message")
+
+// PatEvent 拍一拍事件
+type PatEvent struct {
+	FromUser    string `json:"fromUser"`    // 拍人者 wxid
+	PattedUser  string `json:"pattedUser"`  // 被拍者 wxid
+	ChatUser    string `json:"chatUser"`    // 会话 <wxid or roomid>
+	Template    string `json:"template"`    // 展示文本，占位符已替换为昵称，如 "Alice" 拍了拍 "Bob"
+	RawTemplate string `json:"rawTemplate"` // 原始模板，如 "${wxid_a}" 拍了拍 "${wxid_b}"
+}
+
+type patXML struct {
+	FromUser   string `xml:"fromusername"`
+	ChatUser   string `xml:"chatusername"`
+	PattedUser string `xml:"pattedusername"`
+	Template   string `xml:"template"`
+}
+
+// appmsg type 62 中的拍一拍记录
+type patRecordXML struct {
+	FromUser   string `xml:"fromUser"`
+	PattedUser string `xml:"pattedUser"`
+	Template   string `xml:"templete"`
+}
+
+// 模板中的占位符 ${wxid}
+var patPlaceholder = regexp.MustCompile(`\$\{([^}]+)}`)
+
+// parsePatEvent 解析拍一拍消息 <sysmsg type="pat"> 或 appmsg type 62 中的最后一条记录
+func parsePatEvent(content string) (*PatEvent, error) {
+	if idx := strings.Index(content, "<"); idx > 0 { // 群聊中内容可能带有 "wxid:\n" 前缀
+		content = content[idx:]
+	}
+	var msg struct {
+		Pat     *patXML        `xml:"pat"`
+		Records []patRecordXML `xml:"appmsg>patMsg>records>record"`
+		Chat    string         `xml:"appmsg>patMsg>chatUser"`
+	}
+	if err := xml.Unmarshal([]byte(content), &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal pat err: %w", err)
+	}
+	pat := msg.Pat
+	if pat == nil && len(msg.Records) > 0 {
+		r := msg.Records[len(msg.Records)-1]
+		pat = &patXML{FromUser: r.FromUser, ChatUser: msg.Chat, PattedUser: r.PattedUser, Template: r.Template}
+	}
+	if pat == nil || pat.FromUser == "" {
+		return nil, ErrNotPat
+	}
+	return &PatEvent{
+		FromUser:    pat.FromUser,
+		PattedUser:  pat.PattedUser,
+		ChatUser:    pat.ChatUser,
+		Template:    pat.Template,
+		RawTemplate: pat.Template,
+	}, nil
+}
+
+// resolve 将模板中的占位符替换为昵称
+func (p *PatEvent) resolve(name func(wxid string) string) {
+	p.Template = patPlaceholder.ReplaceAllStringFunc(p.RawTemplate, func(s string) string {
+		return name(patPlaceholder.FindStringSubmatch(s)[1])
+	})
+}
+
+// IsPatted 是否拍了 wxid
+func (p *PatEvent) IsPatted(wxid string) bool {
+	return p.PattedUser == wxid
+}
+
+// SendPat 拍一拍 <群聊 roomid，私聊传好友 wxid> <被拍者 wxid>
+func (c *Client) SendPat(roomId string, wxid string) error {
+	return c.SendPatCtx(c.ctx, roomId, wxid)
+}
+
+// SendPatCtx 拍一拍 <群聊 roomid，私聊传好友 wxid> <被拍者 wxid> <ctx 结束时不再等待，拍一拍仍按队列发出>
+func (c *Client) SendPatCtx(ctx context.Context, roomId string, wxid string) error {
+	_, err := c.Outbox(SendPriorityNormal).Pat(roomId, wxid).WaitCtx(ctx)
+	return err
+}
+
+// Pat 排队拍一拍 <参数同 Client.SendPat，SendResult 为 nil>
+func (o Outbox) Pat(roomId string, wxid string) *SendFuture {
+	c := o.cli
+	return c.enqueue(o.priority, roomId, func() (*SendResult, error) {
+		if _, err := c.wxClient.SendPatCtx(c.ctx, roomId, wxid); err != nil {
+			return nil, fmt.Errorf("wxClient.SendPat err: %w", err)
+		}
+		return nil, nil
+	})
+}
+
+// PatBack 拍回去 <拍一拍消息拍回拍人者，其他消息拍发送者>
+func (m *Message) PatBack() error {
+	if m.PatEvent != nil {
+		return m.meta.SendPat(m.PatEvent.ChatUser, m.PatEvent.FromUser)
+	}
+	if m.IsGroup {
+		return m.meta.SendPat(m.RoomId, m.WxId)
+	}
+	return m.meta.SendPat(m.WxId, m.WxId)
+}
+
+// fillPatEvent 解析拍一拍事件并替换模板中的昵称
+func (c *Client) fillPatEvent(m *Message) {
+	event, err := parsePatEvent(m.Content)
+	if err != nil {
+		logging.Debug("parsePatEvent", map[string]interface{}{"err": err, "xml": m.Content})
+		return
+	}
+	c.setPatEvent(m, event)
+}
+
+// setPatEvent 补全拍一拍所在的会话并解析模板中的昵称
+func (c *Client) setPatEvent(m *Message, event *PatEvent) {
+	if event.ChatUser == "" {
+		event.ChatUser = m.RoomId
+		if event.ChatUser == "" {
+			event.ChatUser = m.WxId
+		}
+	}
+	event.resolve(func(wxid string) string {
+		name, _ := c.memberName(m.RoomId, wxid)
+		return name
+	})
+	m.Type = MsgTypePat
+	m.PatEvent = event
+}
+
+// memberName 成员显示的名称 <群聊中优先使用群昵称，其次是联系人昵称，都没有时返回 wxid> <拍一拍、艾特、引用共用>
+// 群昵称取自群成员缓存或 RoomData，不逐个查询群成员；查询联系人失败时返回 wxid 与错误
+func (c *Client) memberName(roomId string, wxid string) (string, error) {
+	if strings.HasSuffix(roomId, "@chatroom") {
+		if alias, ok := c.groupNickName(roomId, wxid); ok {
+			return alias, nil
+		}
+	}
+	if self, ok := c.GetSelfInfo(); ok && self.Wxid == wxid && self.Name != "" {
+		return self.Name, nil
+	}
+	info, err := c.GetMemberCtx(c.ctx, wxid, true)
+	if err != nil {
+		return wxid, fmt.Errorf("get member %s err: %w", wxid, err)
+	}
+	if info.NickName == "" {
+		return wxid, nil // 查不到昵称时使用 wxid 代替
+	}
+	return info.NickName, nil
+}
+
+// groupNickName 成员的群昵称 <未设置群昵称或不在群内时返回 false>
+func (c *Client) groupNickName(roomId string, wxid string) (string, bool) {
+	if members, ok := c.cacheMember.GetRoomMembers(roomId); ok {
+		for _, m := range members {
+			if m != nil && m.Wxid == wxid { // 群成员的 Alias 为群昵称
+				return m.Alias, m.Alias != ""
+			}
+		}
+		return "", false
+	}
+	rd, err := c.queryRoomData(c.ctx, roomId)
+	if err != nil {
+		logging.Debug("query room data for nickname", map[string]interface{}{"roomId": roomId, "err": err.Error()})
+		return "", false
+	}
+	for _, m := range rd.GetMembers() {
+		if m.GetWxid() == wxid {
+			return m.GetName(), m.GetName() != ""
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,71 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午8:50:00
+// @Desc 引用回复
+package wcf_rpc_sdk
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Clov614/wcf-rpc-sdk/appmsg"
+	"strconv"
+)
+
+var ErrInvalidQuote = errors.New("invalid quote")
+
+// quotedContent 被引用消息在 refermsg 中的类型与内容 <appmsg 只保留标题，微信据此展示引用>
+func quotedContent(quoted *Message) (int, string) {
+	if quoted.AppMsg == nil {
+		return int(quoted.Type), quoted.Content
+	}
+	var doc appmsg.Msg
+	doc.AppMsg.Title, doc.AppMsg.Type = quoted.AppMsg.Title, quoted.AppMsg.Type
+	content, _ := xml.Marshal(doc) // 字段均为字符串与整数，不会出错
+	return int(MsgTypeXML), string(content)
+}
+
+// quoteMsg 生成引用消息 <displayName 为被引用消息发送者的昵称>
+func quoteMsg(text string, quoted *Message, displayName string) (*appmsg.Msg, error) {
+	if text == "" {
+		return nil, fmt.Errorf("%w: empty text", ErrInvalidQuote)
+	}
+	if quoted == nil || quoted.MessageId == 0 {
+		return nil, fmt.Errorf("%w: quoted message without id", ErrInvalidQuote)
+	}
+	refer := appmsg.ReferMsg{
+		SvrId:       strconv.FormatUint(quoted.MessageId, 10),
+		FromUsr:     quoted.WxId,
+		ChatUsr:     quoted.WxId,
+		DisplayName: displayName,
+		CreateTime:  int64(quoted.Ts),
+	}
+	if quoted.IsGroup {
+		refer.FromUsr = quoted.RoomId
+	}
+	refer.Type, refer.Content = quotedContent(quoted)
+	return appmsg.New(AppMsgTypeQuote).Title(text).ReferMsg(refer).Msg(), nil
+}
+
+// SendQuote 发送引用回复 <wxid or roomid> <回复的文本> <被引用的消息>
+func (c *Client) SendQuote(receiver string, text string, quoted *Message) (*SendResult, error) {
+	return c.Outbox(SendPriorityNormal).Quote(receiver, text, quoted).Wait()
+}
+
+// Quote 排队发送引用回复 <参数同 Client.SendQuote>
+func (o Outbox) Quote(receiver string, text string, quoted *Message) *SendFuture {
+	var displayName string
+	if quoted != nil {
+		displayName, _ = o.cli.memberName(quoted.RoomId, quoted.WxId)
+	}
+	msg, err := quoteMsg(text, quoted, displayName)
+	if err != nil {
+		return failedSend(err)
+	}
+	return o.AppMsg(receiver, msg)
+}
+
+// ReplyQuote 引用该消息回复 <群聊中可明确回复的是哪条消息>
+func (m *Message) ReplyQuote(text string) (*SendResult, error) {
+	return m.meta.ReplyQuote(text)
+}
@@ -0,0 +1,181 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午4:50:00
+// @Desc 撤回事件解析与消息留存
+package wcf_rpc_sdk
+
+import (
+	"container/list"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevokeEvent 撤回事件
+type RevokeEvent struct {
+	Session    string   `json:"session"`            // 会话 <wxid or roomid>
+	MsgId      uint64   `json:"msgId"`              // 被撤回消息的本地 id
+	NewMsgId   uint64   `json:"newMsgId"`           // 被撤回消息的 id（与 Message.MessageId 对应）
+	Revoker    string   `json:"revoker"`            // 撤回者 wxid
+	ReplaceMsg string   `json:"replaceMsg"`         // 替换显示的文本，如 "xxx" 撤回了一条消息
+	Original   *Message `json:"original,omitempty"` // 被撤回的原消息 <需要设置 MessageStore，且消息仍在留存期内>
+}
+
+type revokeXML struct {
+	XMLName xml.Name `xml:"sysmsg"`
+	Type    string   `xml:"type,attr"`
+	Revoke  struct {
+		Session    string `xml:"session"`
+		MsgId      uint64 `xml:"msgid"`
+		NewMsgId   uint64 `xml:"newmsgid"`
+		ReplaceMsg string `xml:"replacemsg"`
+	} `xml:"revokemsg"`
+}
+
+// parseRevokeEvent 解析撤回消息 XML <群聊中内容可能带有 "wxid:\n" 前缀>
+func parseRevokeEvent(content string) (*RevokeEvent, error) {
+	if idx := strings.Index(content, "<sysmsg"); idx > 0 {
+		content = content[idx:]
+	}
+	var sys revokeXML
+	if err := xml.Unmarshal([]byte(content), &sys); err != nil {
+		return nil, fmt.Errorf("unmarshal revokemsg err: %w", err)
+	}
+	if sys.Type != "revokemsg" || sys.Revoke.NewMsgId == 0 {
+		return nil, errors.New("not a revokemsg sysmsg")
+	}
+	return &RevokeEvent{
+		Session:    sys.Revoke.Session,
+		MsgId:      sys.Revoke.MsgId,
+		NewMsgId:   sys.Revoke.NewMsgId,
+		ReplaceMsg: sys.Revoke.ReplaceMsg,
+	}, nil
+}
+
+// MessageStore 消息留存 <用于撤回事件找回原消息>
+type MessageStore interface {
+	Put(msg *Message)
+	Get(id uint64) (*Message, bool)
+}
+
+// MemoryMessageStore 内存消息留存 <超过容量时淘汰最早的消息，超过留存时长的消息不再返回>
+type MemoryMessageStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // 按写入顺序排列的 *storedMsg
+	index    map[uint64]*list.Element
+}
+
+type storedMsg struct {
+	msg *Message
+	at  time.Time
+}
+
+const (
+	DefaultStoreCapacity = 10000           // 默认留存的消息数
+	DefaultStoreTTL      = 5 * time.Minute // 默认留存时长 <微信只能撤回两分钟内的消息>
+)
+
+// NewMemoryMessageStore 创建内存消息留存 <容量> <留存时长> <非正数使用默认值>
+func NewMemoryMessageStore(capacity int, ttl time.Duration) *MemoryMessageStore {
+	if capacity <= 0 {
+		capacity = DefaultStoreCapacity
+	}
+	if ttl <= 0 {
+		ttl = DefaultStoreTTL
+	}
+	return &MemoryMessageStore{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		index:    make(map[uint64]*list.Element),
+	}
+}
+
+// Put 留存消息
+func (s *MemoryMessageStore) Put(msg *Message) {
+	if msg == nil || msg.MessageId == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.index[msg.MessageId]; ok {
+		s.order.Remove(e)
+	}
+	s.index[msg.MessageId] = s.order.PushBack(&storedMsg{msg: msg, at: time.Now()})
+	s.evict()
+}
+
+// Get 取出留存的消息
+func (s *MemoryMessageStore) Get(id uint64) (*Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict()
+	e, ok := s.index[id]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*storedMsg).msg, true
+}
+
+// Len 当前留存的消息数
+func (s *MemoryMessageStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// evict 淘汰超出容量或过期的消息 <调用方持有锁>
+func (s *MemoryMessageStore) evict() {
+	deadline := time.Now().Add(-s.ttl)
+	for e := s.order.Front(); e != nil; e = s.order.Front() {
+		sm := e.Value.(*storedMsg)
+		if s.order.Len() <= s.capacity && sm.at.After(deadline) {
+			return
+		}
+		s.order.Remove(e)
+		delete(s.index, sm.msg.MessageId)
+	}
+}
+
+// SetMessageStore 设置消息留存 <设置后收到的消息会写入留存，撤回事件可通过 RevokeEvent.Original 取得原消息> <传入 nil 关闭>
+func (c *Client) SetMessageStore(store MessageStore) {
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+	c.msgStore = store
+}
+
+func (c *Client) messageStore() MessageStore {
+	c.storeMu.RLock()
+	defer c.storeMu.RUnlock()
+	return c.msgStore
+}
+
+// fillRevokeEvent 解析撤回事件并找回原消息
+func (c *Client) fillRevokeEvent(m *Message) {
+	event, err := parseRevokeEvent(m.Content)
+	if err != nil {
+		logging.Debug("parseRevokeEvent", map[string]interface{}{"err": err, "xml": m.Content})
+		return
+	}
+	event.Revoker = m.WxId
+	if m.IsSelf {
+		if self, ok := c.GetSelfWxId(); ok {
+			event.Revoker = self
+		}
+	}
+	if store := c.messageStore(); store != nil {
+		if original, ok := store.Get(event.NewMsgId); ok {
+			event.Original = original
+			if event.Revoker == "" || event.Revoker == m.RoomId {
+				event.Revoker = original.WxId
+			}
+		}
+	}
+	m.RevokeEvent = event
+}
@@ -0,0 +1,149 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午6:30:00
+// @Desc 群管理（添加、邀请、移出成员，查询群主与成员）
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"strings"
+)
+
+var (
+	ErrNotInRoom      = errors.New("bot is not in the chat room")
+	ErrNoMembers      = errors.New("no member wxid given")
+	ErrRoomOpRejected = errors.New("room operation rejected by wechat") // 状态码为 0 等非成功值，如无权限、成员无法添加
+	ErrRoomOpFailed   = errors.New("room operation failed")             // 状态码为负数，wcf 执行失败
+)
+
+// RoomOp 群操作
+type RoomOp string
+
+const (
+	RoomOpAdd    RoomOp = "add"    // 直接拉人（40 人以下的群）
+	RoomOpInvite RoomOp = "invite" // 发送邀请（40 人及以上的群）
+	RoomOpRemove RoomOp = "remove" // 移出群聊（需要群主或管理员）
+)
+
+// RoomOpError 群操作失败 <可通过 errors.Is 判断 ErrRoomOpRejected / ErrRoomOpFailed，errors.As 取得 StatusError>
+type RoomOpError struct {
+	Op     RoomOp
+	RoomId string
+	Wxids  []string
+	Status int32
+	Err    error
+}
+
+func (e *RoomOpError) Error() string {
+	return fmt.Sprintf("room %s %s [%s] status %d: %v", e.Op, e.RoomId, strings.Join(e.Wxids, ","), e.Status, e.Err)
+}
+
+func (e *RoomOpError) Unwrap() []error {
+	if e.Status < 0 {
+		return []error{ErrRoomOpFailed, e.Err}
+	}
+	return []error{ErrRoomOpRejected, e.Err}
+}
+
+// Rooms 群管理 <所有操作都会先确认机器人在群内>
+type Rooms struct {
+	cli *Client
+}
+
+// Rooms 群管理
+func (c *Client) Rooms() *Rooms {
+	return &Rooms{cli: c}
+}
+
+// Add 拉成员进群
+func (r *Rooms) Add(roomId string, wxids ...string) error {
+	return r.AddCtx(r.cli.ctx, roomId, wxids...)
+}
+
+// AddCtx 拉成员进群
+func (r *Rooms) AddCtx(ctx context.Context, roomId string, wxids ...string) error {
+	return r.exec(ctx, RoomOpAdd, roomId, wxids, r.cli.wxClient.AddChatRoomMembersCtx)
+}
+
+// Invite 邀请成员进群
+func (r *Rooms) Invite(roomId string, wxids ...string) error {
+	return r.InviteCtx(r.cli.ctx, roomId, wxids...)
+}
+
+// InviteCtx 邀请成员进群
+func (r *Rooms) InviteCtx(ctx context.Context, roomId string, wxids ...string) error {
+	return r.exec(ctx, RoomOpInvite, roomId, wxids, r.cli.wxClient.InvChatRoomMembersCtx)
+}
+
+// Remove 将成员移出群聊
+func (r *Rooms) Remove(roomId string, wxids ...string) error {
+	return r.RemoveCtx(r.cli.ctx, roomId, wxids...)
+}
+
+// RemoveCtx 将成员移出群聊
+func (r *Rooms) RemoveCtx(ctx context.Context, roomId string, wxids ...string) error {
+	return r.exec(ctx, RoomOpRemove, roomId, wxids, r.cli.wxClient.DelChatRoomMembersCtx)
+}
+
+// Owner 群主信息
+func (r *Rooms) Owner(roomId string) (*ContactInfo, error) {
+	return r.OwnerCtx(r.cli.ctx, roomId)
+}
+
+// OwnerCtx 群主信息
+func (r *Rooms) OwnerCtx(ctx context.Context, roomId string) (*ContactInfo, error) {
+	if err := r.ensureIn(roomId); err != nil {
+		return nil, err
+	}
+	return r.cli.ChatRoomOwnerCtx(ctx, roomId)
+}
+
+// Members 群成员列表
+func (r *Rooms) Members(roomId string) ([]*ContactInfo, error) {
+	return r.MembersCtx(r.cli.ctx, roomId)
+}
+
+// MembersCtx 群成员列表
+func (r *Rooms) MembersCtx(ctx context.Context, roomId string) ([]*ContactInfo, error) {
+	if err := r.ensureIn(roomId); err != nil {
+		return nil, err
+	}
+	return r.cli.RoomMembersCtx(ctx, roomId)
+}
+
+// exec 执行成员操作，成功后清除群成员缓存
+func (r *Rooms) exec(ctx context.Context, op RoomOp, roomId string, wxids []string,
+	call func(ctx context.Context, roomId string, wxIds []string) (int32, error)) error {
+	if len(wxids) == 0 {
+		return ErrNoMembers
+	}
+	if err := r.ensureIn(roomId); err != nil {
+		return err
+	}
+	status, err := call(ctx, roomId, wxids)
+	if err != nil {
+		var se *StatusError
+		if errors.As(err, &se) {
+			return &RoomOpError{Op: op, RoomId: roomId, Wxids: wxids, Status: se.Status, Err: err}
+		}
+		return fmt.Errorf("room %s err: %w", op, err)
+	}
+	logging.Debug("room operation done", map[string]interface{}{"op": op, "roomId": roomId, "wxids": wxids, "status": status})
+	r.cli.cacheMember.InvalidateRoom(roomId)
+	return nil
+}
+
+// ensureIn 确认机器人在群内 <通讯录缓存中没有该群时刷新一次>
+func (r *Rooms) ensureIn(roomId string) error {
+	if in, ok := r.cli.self.IsInRoom(roomId); ok && in {
+		return nil
+	}
+	r.cli.self.UpdateContact()
+	if in, _ := r.cli.self.IsInRoom(roomId); in {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotInRoom, roomId)
+}
@@ -0,0 +1,485 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/19 上午12:40:00
+// @Desc 事件路由：过滤条件、中间件、优先级与并发处理
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Clov614/logging"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRouterWorkers Router 默认的并发处理数
+const DefaultRouterWorkers = 8
+
+var (
+	ErrHandlerPanic = errors.New("handler panic")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// HandlerFunc 事件处理器 <返回的错误交给 Router.OnError>
+type HandlerFunc func(ctx *Context) error
+
+// Middleware 包装处理器 <在 next 前后执行逻辑，不调用 next 即拦截>
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Filter 过滤条件 <返回 true 时处理器才会执行>
+type Filter func(ev MessageEvent) bool
+
+// Context 处理器的上下文 <嵌入 *Message，可直接访问消息字段与回复方法>
+type Context struct {
+	*Message
+	Ctx     context.Context // Router.Run 的 ctx
+	Event   MessageEvent    // 事件 <可 type switch 取得具体类型>
+	Client  *Client
+	Matches []string // OnRegex 的匹配结果 <Matches[0] 为整体，其后为分组>
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+// Stop 阻止后续（优先级更低的）处理器执行
+func (ctx *Context) Stop() {
+	ctx.mu.Lock()
+	ctx.stopped = true
+	ctx.mu.Unlock()
+}
+
+// IsStopped 是否已调用 Stop
+func (ctx *Context) IsStopped() bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.stopped
+}
+
+// Route 注册的处理器
+type Route struct {
+	router      *Router
+	priority    int
+	seq         int // 注册顺序，同优先级先注册的先执行
+	filters     []Filter
+	middlewares []Middleware
+	handler     HandlerFunc
+}
+
+// Priority 设置优先级 <数值大的先执行，默认 0>
+func (rt *Route) Priority(p int) *Route {
+	r := rt.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt.priority = p
+	r.sortRoutes()
+	return rt
+}
+
+// Filter 追加过滤条件 <全部满足才执行>
+func (rt *Route) Filter(filters ...Filter) *Route {
+	rt.router.mu.Lock()
+	defer rt.router.mu.Unlock()
+	rt.filters = append(rt.filters, filters...)
+	return rt
+}
+
+// Use 追加只作用于该处理器的中间件 <在 Router.Use 的中间件之内执行>
+func (rt *Route) Use(mws ...Middleware) *Route {
+	rt.router.mu.Lock()
+	defer rt.router.mu.Unlock()
+	rt.middlewares = append(rt.middlewares, mws...)
+	return rt
+}
+
+func (rt *Route) match(ev MessageEvent) bool {
+	for _, f := range rt.filters {
+		if !f(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// Router 事件路由 <消费 Client.Events，按优先级依次执行匹配的处理器>
+//
+//	r := wcf_rpc_sdk.NewRouter(client, 0)
+//	r.Use(wcf_rpc_sdk.Logger())
+//	r.OnRegex(`^/echo (.+)`, func(ctx *wcf_rpc_sdk.Context) error {
+//		_, err := ctx.ReplyText(ctx.Matches[1])
+//		return err
+//	})
+//	r.Run(ctx)
+type Router struct {
+	cli         *Client
+	workers     int
+	mu          sync.RWMutex
+	routes      []*Route
+	middlewares []Middleware
+	seq         int
+	onError     func(ctx *Context, err error)
+	onState     []func(e *LoginStateChanged)
+}
+
+// NewRouter <客户端> <并发处理的事件数，<= 0 时为 DefaultRouterWorkers>
+func NewRouter(cli *Client, workers int) *Router {
+	if workers <= 0 {
+		workers = DefaultRouterWorkers
+	}
+	return &Router{cli: cli, workers: workers}
+}
+
+// Use 追加作用于所有处理器的中间件 <先添加的在外层>
+func (r *Router) Use(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, mws...)
+}
+
+// OnError 设置处理器返回错误时的回调 <默认记录日志>
+func (r *Router) OnError(fn func(ctx *Context, err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = fn
+}
+
+// OnLoginState 连接或登录状态变化时调用 <在 Run 的协程中执行，不应阻塞>
+func (r *Router) OnLoginState(fn func(e *LoginStateChanged)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onState = append(r.onState, fn)
+}
+
+// Handle 注册处理器 <满足全部过滤条件时执行，无条件时处理所有消息事件>
+func (r *Router) Handle(h HandlerFunc, filters ...Filter) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	rt := &Route{router: r, seq: r.seq, filters: filters, handler: h}
+	r.routes = append(r.routes, rt)
+	r.sortRoutes()
+	return rt
+}
+
+func (r *Router) sortRoutes() {
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		if r.routes[i].priority != r.routes[j].priority {
+			return r.routes[i].priority > r.routes[j].priority
+		}
+		return r.routes[i].seq < r.routes[j].seq
+	})
+}
+
+// OnText 文本消息 <包括引用回复>
+func (r *Router) OnText(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsText}, filters...)...)
+}
+
+// OnImage 图片消息
+func (r *Router) OnImage(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsImage}, filters...)...)
+}
+
+// OnVoice 语音消息
+func (r *Router) OnVoice(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsVoice}, filters...)...)
+}
+
+// OnAppMsg 链接、文件、小程序等 appmsg
+func (r *Router) OnAppMsg(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsAppMsg}, filters...)...)
+}
+
+// OnFriendRequest 好友请求
+func (r *Router) OnFriendRequest(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsFriendRequest}, filters...)...)
+}
+
+// OnMembersJoined 成员加入群聊
+func (r *Router) OnMembersJoined(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsMembersJoined}, filters...)...)
+}
+
+// OnPat 拍一拍
+func (r *Router) OnPat(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsPat}, filters...)...)
+}
+
+// OnRevoked 消息撤回
+func (r *Router) OnRevoked(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{IsRevoked}, filters...)...)
+}
+
+// OnGroup 群聊中的消息事件
+func (r *Router) OnGroup(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{InGroup}, filters...)...)
+}
+
+// OnPrivate 私聊中的消息事件 <不含公众号>
+func (r *Router) OnPrivate(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{InPrivate}, filters...)...)
+}
+
+// OnAtSelf 群聊中艾特自己的消息 <RoomData.IsAtSelf>
+func (r *Router) OnAtSelf(h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{AtSelf}, filters...)...)
+}
+
+// OnFromRoom 指定群聊中的消息事件
+func (r *Router) OnFromRoom(roomId string, h HandlerFunc, filters ...Filter) *Route {
+	return r.Handle(h, append([]Filter{FromRoom(roomId)}, filters...)...)
+}
+
+// OnRegex 内容匹配正则的文本消息 <匹配结果写入 Context.Matches> <pattern 无法编译时 panic>
+func (r *Router) OnRegex(pattern string, h HandlerFunc, filters ...Filter) *Route {
+	re := regexp.MustCompile(pattern)
+	wrapped := func(ctx *Context) error {
+		ctx.Matches = re.FindStringSubmatch(ctx.Content)
+		return h(ctx)
+	}
+	return r.Handle(wrapped, append([]Filter{IsText, Regex(re)}, filters...)...)
+}
+
+// Run 消费 Client.Events 直到 ctx 或客户端结束 <同时最多处理 workers 个事件，处理器全忙时暂停读取>
+func (r *Router) Run(ctx context.Context) error {
+	events := r.cli.Events()
+	jobs := make(chan MessageEvent)
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ev := range jobs {
+				r.Dispatch(ctx, ev)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+	for {
+		var ev Event
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.cli.ctx.Done():
+			return ErrClosed
+		case ev, ok = <-events:
+			if !ok { // 客户端已关闭
+				return ErrClosed
+			}
+		}
+		switch e := ev.(type) {
+		case *LoginStateChanged:
+			r.stateChanged(e)
+		case MessageEvent:
+			select {
+			case jobs <- e:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-r.cli.ctx.Done():
+				return ErrClosed
+			}
+		}
+	}
+}
+
+func (r *Router) stateChanged(e *LoginStateChanged) {
+	r.mu.RLock()
+	fns := r.onState
+	r.mu.RUnlock()
+	for _, fn := range fns {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					logging.Error("login state handler panic", map[string]interface{}{"panic": fmt.Sprint(p), "stack": string(debug.Stack())})
+				}
+			}()
+			fn(e)
+		}()
+	}
+}
+
+// Dispatch 在当前协程中处理一个事件 <按优先级执行匹配的处理器，直到某个处理器调用 Context.Stop>
+func (r *Router) Dispatch(ctx context.Context, ev MessageEvent) {
+	r.mu.RLock()
+	routes := make([]Route, len(r.routes)) // 复制后在锁外执行，过滤条件与中间件中可以再注册处理器
+	for i, rt := range r.routes {
+		routes[i] = *rt
+	}
+	global := r.middlewares
+	onError := r.onError
+	r.mu.RUnlock()
+
+	hc := &Context{Message: ev.Msg(), Ctx: ctx, Event: ev, Client: r.cli}
+	for i := range routes {
+		rt := &routes[i]
+		if !rt.match(ev) {
+			continue
+		}
+		hc.Matches = nil
+		if err := safeCall(chain(chain(rt.handler, rt.middlewares), global), hc); err != nil {
+			if onError != nil {
+				onError(hc, err)
+			} else {
+				logging.ErrorWithErr(err, "router handler", map[string]interface{}{"message_id": hc.MessageId, "type": hc.Type})
+			}
+		}
+		if hc.IsStopped() {
+			return
+		}
+	}
+}
+
+// chain 以 mws 包装 h <mws[0] 在最外层>
+func chain(h HandlerFunc, mws []Middleware) HandlerFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// safeCall 执行处理器，panic 转换为 ErrHandlerPanic
+func safeCall(h HandlerFunc, ctx *Context) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logging.Error("handler panic", map[string]interface{}{"panic": fmt.Sprint(p), "stack": string(debug.Stack())})
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, p)
+		}
+	}()
+	return h(ctx)
+}
+
+// Recovery 将处理器的 panic 转换为 ErrHandlerPanic <Router 总会兜底，放在 Logger 之后可让日志记录到 panic>
+func Recovery() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			return safeCall(next, ctx)
+		}
+	}
+}
+
+// Logger 记录每次处理的事件、耗时与错误
+func Logger() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			start := time.Now()
+			err := next(ctx)
+			fields := map[string]interface{}{
+				"event":      fmt.Sprintf("%T", ctx.Event),
+				"message_id": ctx.MessageId,
+				"room_id":    ctx.RoomId,
+				"wxid":       ctx.WxId,
+				"cost":       time.Since(start).String(),
+			}
+			if err != nil {
+				logging.WarnWithErr(err, "router handle", fields)
+			} else {
+				logging.Debug("router handle", fields)
+			}
+			return err
+		}
+	}
+}
+
+// Auth 仅允许满足 allow 的事件通过 <其余事件返回 ErrUnauthorized，不执行处理器>
+//
+//	r.OnRegex(`^/kick (\S+)`, kick).Use(wcf_rpc_sdk.Auth(wcf_rpc_sdk.FromSender(adminWxid)))
+func Auth(allow Filter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) error {
+			if !allow(ctx.Event) {
+				return fmt.Errorf("%w: %s", ErrUnauthorized, ctx.WxId)
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// 事件类型的过滤条件
+var (
+	IsText          Filter = func(ev MessageEvent) bool { _, ok := ev.(*TextMessage); return ok }
+	IsImage         Filter = func(ev MessageEvent) bool { _, ok := ev.(*ImageMessage); return ok }
+	IsVoice         Filter = func(ev MessageEvent) bool { _, ok := ev.(*VoiceMessage); return ok }
+	IsAppMsg        Filter = func(ev MessageEvent) bool { _, ok := ev.(*AppMessage); return ok }
+	IsFriendRequest Filter = func(ev MessageEvent) bool { _, ok := ev.(*FriendRequest); return ok }
+	IsMembersJoined Filter = func(ev MessageEvent) bool { _, ok := ev.(*MembersJoined); return ok }
+	IsPat           Filter = func(ev MessageEvent) bool { _, ok := ev.(*Pat); return ok }
+	IsRevoked       Filter = func(ev MessageEvent) bool { _, ok := ev.(*Revoked); return ok }
+)
+
+// 会话的过滤条件
+var (
+	InGroup   Filter = func(ev MessageEvent) bool { return ev.Msg().IsGroup }
+	InPrivate Filter = func(ev MessageEvent) bool { m := ev.Msg(); return !m.IsGroup && !m.IsGH }
+	AtSelf    Filter = func(ev MessageEvent) bool { m := ev.Msg(); return m.RoomData != nil && m.RoomData.IsAtSelf }
+	NotSelf   Filter = func(ev MessageEvent) bool { return !ev.Msg().IsSelf } // 排除自己发送的消息
+)
+
+// FromRoom 来自指定群聊之一
+func FromRoom(roomIds ...string) Filter {
+	set := toSet(roomIds)
+	return func(ev MessageEvent) bool {
+		m := ev.Msg()
+		return m.IsGroup && set[m.RoomId]
+	}
+}
+
+// FromSender 由指定的 wxid 之一发送
+func FromSender(wxids ...string) Filter {
+	set := toSet(wxids)
+	return func(ev MessageEvent) bool {
+		return set[ev.Msg().WxId]
+	}
+}
+
+// Regex 内容匹配正则
+func Regex(re *regexp.Regexp) Filter {
+	return func(ev MessageEvent) bool {
+		return re.MatchString(ev.Msg().Content)
+	}
+}
+
+// And 全部满足
+func And(filters ...Filter) Filter {
+	return func(ev MessageEvent) bool {
+		for _, f := range filters {
+			if !f(ev) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or 任一满足
+func Or(filters ...Filter) Filter {
+	return func(ev MessageEvent) bool {
+		for _, f := range filters {
+			if f(ev) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not 取反
+func Not(f Filter) Filter {
+	return func(ev MessageEvent) bool {
+		return !f(ev)
+	}
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
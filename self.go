@@ -55,12 +55,16 @@ func (s *Self) getSelfInfo(getLatest bool) SelfInfo {
 		if info == nil || info.Wxid == "" {
 			return SelfInfo{}
 		}
+		s.mu.Lock()
 		s.Wxid = info.Wxid
 		s.Name = info.Name
 		s.Home = info.Home
 		s.Mobile = info.Mobile
 		s.FileStoragePath = filepath.Join(info.Home, info.Wxid, "FileStorage")
+		s.mu.Unlock()
 	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return SelfInfo{
 		Wxid:            s.Wxid,
 		Name:            s.Name,
@@ -0,0 +1,361 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午10:50:00
+// @Desc 发送队列：全局与单会话限速、随机抖动、优先级
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrSendQueueFull = errors.New("send queue is full")
+
+// SendPriority 发送优先级 <同一优先级先进先出>
+type SendPriority int
+
+const (
+	SendPriorityLow    SendPriority = iota // 群发、定时推送等
+	SendPriorityNormal                     // Client.SendXxx 的默认优先级
+	SendPriorityHigh                       // 回复消息 <Message.ReplyXxx>
+)
+
+func (p SendPriority) String() string {
+	switch p {
+	case SendPriorityLow:
+		return "low"
+	case SendPriorityNormal:
+		return "normal"
+	case SendPriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// SendPolicy 发送限速策略 <令牌桶：每秒补充 Rate 个，最多累积 Burst 个> <零值为不限速、不等待>
+type SendPolicy struct {
+	GlobalRate    float64       // 所有会话合计每秒发送条数 <<= 0 为不限制>
+	GlobalBurst   int           // 所有会话合计的突发条数 <限速时至少为 1>
+	ReceiverRate  float64       // 单个会话每秒发送条数 <<= 0 为不限制>
+	ReceiverBurst int           // 单个会话的突发条数 <限速时至少为 1>
+	MinJitter     time.Duration // 同一会话两次发送之间随机等待的下限
+	MaxJitter     time.Duration // 同一会话两次发送之间随机等待的上限 <<= 0 为不等待> <不阻塞其他会话>
+	QueueSize     int           // 排队的最大条数 <超出时返回 ErrSendQueueFull，<= 0 为 DefaultSendQueueSize>
+}
+
+// DefaultSendQueueSize 默认排队的最大条数
+const DefaultSendQueueSize = 512
+
+// DefaultSendPolicy 默认的发送策略 <不限速、不等待，与未引入发送队列时的行为一致>
+var DefaultSendPolicy = SendPolicy{QueueSize: DefaultSendQueueSize}
+
+// RecommendedSendPolicy 建议的限速策略 <机器人在大群中频繁回复时，通过 SetSendPolicy 开启>
+var RecommendedSendPolicy = SendPolicy{
+	GlobalRate:    2,
+	GlobalBurst:   5,
+	ReceiverRate:  0.5,
+	ReceiverBurst: 3,
+	MinJitter:     300 * time.Millisecond,
+	MaxJitter:     1200 * time.Millisecond,
+	QueueSize:     DefaultSendQueueSize,
+}
+
+// 单会话令牌桶超过该数量时，清理已经补满（即闲置）的令牌桶
+const maxIdleBuckets = 1024
+
+// SetSendPolicy 设置发送限速策略 <如 RecommendedSendPolicy>
+func (c *Client) SetSendPolicy(p SendPolicy) {
+	p.GlobalBurst = max(p.GlobalBurst, 1)
+	p.ReceiverBurst = max(p.ReceiverBurst, 1)
+	p.MinJitter = min(max(p.MinJitter, 0), max(p.MaxJitter, 0))
+	if p.QueueSize <= 0 {
+		p.QueueSize = DefaultSendQueueSize
+	}
+	c.sq.setPolicy(p)
+}
+
+// SendFuture 排队发送的结果
+type SendFuture struct {
+	done chan struct{}
+	res  *SendResult
+	err  error
+}
+
+func newSendFuture() *SendFuture {
+	return &SendFuture{done: make(chan struct{})}
+}
+
+func (f *SendFuture) resolve(res *SendResult, err error) {
+	f.res, f.err = res, err
+	close(f.done)
+}
+
+// Done 发送完成或失败后关闭
+func (f *SendFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待发送完成
+func (f *SendFuture) Wait() (*SendResult, error) {
+	<-f.done
+	return f.res, f.err
+}
+
+// WaitCtx 等待发送完成 <ctx 结束时返回 ctx 的错误，消息仍留在队列中>
+func (f *SendFuture) WaitCtx(ctx context.Context) (*SendResult, error) {
+	select {
+	case <-f.done:
+		return f.res, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tokenBucket 令牌桶 <rate <= 0 时不限制> <notBefore 之前不发送，用于随机等待>
+type tokenBucket struct {
+	rate      float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	notBefore time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// delay 距离可以发送（取得一个令牌且过了 notBefore）还需等待的时间
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	wait := max(b.notBefore.Sub(now), 0)
+	if b.rate <= 0 {
+		return wait
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		return wait
+	}
+	return max(wait, time.Duration((1-b.tokens)/b.rate*float64(time.Second))+time.Millisecond)
+}
+
+func (b *tokenBucket) take(now time.Time) {
+	if b.rate <= 0 {
+		return
+	}
+	b.refill(now)
+	b.tokens--
+}
+
+func (b *tokenBucket) idle(now time.Time) bool {
+	if now.Before(b.notBefore) {
+		return false
+	}
+	b.refill(now)
+	return b.rate <= 0 || b.tokens >= b.burst
+}
+
+// sendTask 排队中的发送
+type sendTask struct {
+	receiver string
+	send     func() (*SendResult, error)
+	cleanup  func() // 发送结束（含失败、未发出）后调用 <可选>
+	future   *SendFuture
+}
+
+func (t *sendTask) finish(res *SendResult, err error) {
+	if t.cleanup != nil {
+		t.cleanup()
+	}
+	t.future.resolve(res, err)
+}
+
+// sendQueue 发送队列 <由单个协程按优先级与限速依次发送>
+type sendQueue struct {
+	mu        sync.Mutex
+	policy    SendPolicy
+	lanes     [SendPriorityHigh + 1][]*sendTask
+	pending   int
+	global    *tokenBucket
+	receivers map[string]*tokenBucket
+	wake      chan struct{}
+	closed    bool
+}
+
+func newSendQueue() *sendQueue {
+	q := &sendQueue{wake: make(chan struct{}, 1)}
+	q.setPolicy(DefaultSendPolicy)
+	return q
+}
+
+func (q *sendQueue) setPolicy(p SendPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policy = p
+	q.global = newTokenBucket(p.GlobalRate, p.GlobalBurst, time.Now())
+	q.receivers = make(map[string]*tokenBucket)
+}
+
+func (q *sendQueue) push(priority SendPriority, task *sendTask) error {
+	if priority < SendPriorityLow || priority > SendPriorityHigh {
+		priority = SendPriorityNormal
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	if q.pending >= q.policy.QueueSize {
+		return fmt.Errorf("%w: %d pending", ErrSendQueueFull, q.pending)
+	}
+	q.lanes[priority] = append(q.lanes[priority], task)
+	q.pending++
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *sendQueue) bucket(receiver string, now time.Time) *tokenBucket {
+	b, ok := q.receivers[receiver]
+	if ok {
+		return b
+	}
+	if len(q.receivers) >= maxIdleBuckets {
+		for r, rb := range q.receivers {
+			if rb.idle(now) {
+				delete(q.receivers, r)
+			}
+		}
+	}
+	b = newTokenBucket(q.policy.ReceiverRate, q.policy.ReceiverBurst, now)
+	q.receivers[receiver] = b
+	return b
+}
+
+// next 取出可以发送的任务 <优先级高的先发；同一优先级中每个会话只考虑最早的一条，保证先进先出；会话被限速时跳过，不阻塞其他会话>
+// 没有可发送的任务时返回需要等待的时间 <0 为等待新任务>
+func (q *sendQueue) next(now time.Time) (*sendTask, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == 0 {
+		return nil, 0
+	}
+	if d := q.global.delay(now); d > 0 {
+		return nil, d
+	}
+	var wait time.Duration
+	for p := SendPriorityHigh; p >= SendPriorityLow; p-- {
+		seen := make(map[string]bool)
+		for i, task := range q.lanes[p] {
+			if seen[task.receiver] { // 该会话前面还有未发出的消息
+				continue
+			}
+			seen[task.receiver] = true
+			b := q.bucket(task.receiver, now)
+			if d := b.delay(now); d > 0 {
+				if wait == 0 || d < wait {
+					wait = d
+				}
+				continue
+			}
+			q.lanes[p] = append(q.lanes[p][:i], q.lanes[p][i+1:]...)
+			q.pending--
+			q.global.take(now)
+			b.take(now)
+			b.notBefore = now.Add(q.jitter()) // 同一会话的下一条随机等待
+			return task, 0
+		}
+	}
+	return nil, wait
+}
+
+// jitter 随机等待的时长 <调用方持有 q.mu>
+func (q *sendQueue) jitter() time.Duration {
+	lo, hi := q.policy.MinJitter, q.policy.MaxJitter
+	if hi <= 0 {
+		return 0
+	}
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)+1))
+}
+
+// close 关闭队列，排队中的任务均返回 ErrClosed
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	for p := range q.lanes {
+		for _, task := range q.lanes[p] {
+			task.finish(nil, ErrClosed)
+		}
+		q.lanes[p] = nil
+	}
+	q.pending = 0
+}
+
+// enqueue 将发送加入队列 <send 在发送协程中执行>
+func (c *Client) enqueue(priority SendPriority, receiver string, send func() (*SendResult, error)) *SendFuture {
+	return c.enqueueTask(priority, &sendTask{receiver: receiver, send: send})
+}
+
+func (c *Client) enqueueTask(priority SendPriority, task *sendTask) *SendFuture {
+	task.future = newSendFuture()
+	if err := c.sq.push(priority, task); err != nil {
+		task.finish(nil, err)
+	}
+	return task.future
+}
+
+// failedSend 未能加入队列的发送
+func failedSend(err error) *SendFuture {
+	f := newSendFuture()
+	f.resolve(nil, err)
+	return f
+}
+
+// runSendQueue 发送协程 <客户端关闭时退出>
+func (c *Client) runSendQueue() {
+	defer c.sq.close()
+	for {
+		task, wait := c.sq.next(time.Now())
+		if task == nil {
+			var timer <-chan time.Time
+			if wait > 0 {
+				timer = time.After(wait)
+			}
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-c.sq.wake:
+			case <-timer:
+			}
+			continue
+		}
+		task.finish(task.send())
+	}
+}
+
+// Outbox 以指定优先级排队发送 <方法不等待发送完成，通过 SendFuture 取得结果>
+//
+//	f := client.Outbox(wcf_rpc_sdk.SendPriorityLow).Text(roomId, "早安")
+//	sent, err := f.Wait()
+type Outbox struct {
+	cli      *Client
+	priority SendPriority
+}
+
+// Outbox 指定优先级的发送队列入口 <Client.SendXxx 使用 SendPriorityNormal，Message.ReplyXxx 使用 SendPriorityHigh>
+func (c *Client) Outbox(priority SendPriority) Outbox {
+	return Outbox{cli: c, priority: priority}
+}
@@ -0,0 +1,153 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午2:40:00
+// @Desc 发送结果与消息撤回
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMsgNotFound = errors.New("sent message not found")     // 库中找不到刚发送的消息
+	ErrNotSelfMsg  = errors.New("can not revoke others' msg") // 只能撤回自己发送的消息
+)
+
+const (
+	sentLookupTimeout  = 5 * time.Second        // MsgId 查找的默认超时
+	sentLookupInterval = 300 * time.Millisecond // 消息写入库前的重试间隔
+)
+
+// 消息库按大小分片为 MSG0.db、MSG1.db ...，新消息写在编号最大的库中
+var msgDBPattern = regexp.MustCompile(`^MSG(\d+)\.db$`)
+
+// SendResult 发送结果 <wcf 的发送接口不返回消息 id，需要时通过 MsgId 在库中查找>
+type SendResult struct {
+	Receiver string  `json:"receiver"` // 接收者 <wxid or roomid>
+	Type     MsgType `json:"type"`     // 消息在库中的类型
+	Ts       int64   `json:"ts"`       // 发送时间（秒）
+
+	cli     *Client
+	content string // 文本消息的内容，用于区分同一秒内发出的多条消息
+	mu      sync.Mutex
+	msgId   uint64
+}
+
+func (c *Client) newSendResult(receiver string, msgType MsgType) *SendResult {
+	return &SendResult{Receiver: receiver, Type: msgType, Ts: time.Now().Unix(), cli: c}
+}
+
+func (c *Client) newTextSendResult(receiver string, content string) *SendResult {
+	r := c.newSendResult(receiver, MsgTypeText)
+	r.content = content
+	return r
+}
+
+// MsgId 查找刚发送的消息 id（MsgSvrID） <消息写入库有延迟，最多等待 5 秒>
+func (r *SendResult) MsgId() (uint64, error) {
+	ctx, cancel := context.WithTimeout(r.cli.ctx, sentLookupTimeout)
+	defer cancel()
+	return r.MsgIdCtx(ctx)
+}
+
+// MsgIdCtx 查找刚发送的消息 id <直到找到或 ctx 结束>
+// 查找条件为：最新的消息库中，发往 Receiver、类型为 Type、发送时间不早于 Ts 的最后一条自己发送的消息（文本消息同时匹配内容）
+func (r *SendResult) MsgIdCtx(ctx context.Context) (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.msgId != 0 {
+		return r.msgId, nil
+	}
+	for {
+		id, err := r.cli.findSentMsgId(ctx, r.Receiver, r.Type, r.Ts, r.content)
+		if err != nil {
+			return 0, err
+		}
+		if id != 0 {
+			r.msgId = id
+			return id, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("%w: receiver %s, ts %d", ErrMsgNotFound, r.Receiver, r.Ts)
+		case <-time.After(sentLookupInterval):
+		}
+	}
+}
+
+// Revoke 撤回该消息 <只能撤回两分钟内的消息>
+func (r *SendResult) Revoke() error {
+	id, err := r.MsgId()
+	if err != nil {
+		return err
+	}
+	return r.cli.RevokeMsg(id)
+}
+
+// RevokeMsg 撤回消息 <消息 id（MsgSvrID）>
+func (c *Client) RevokeMsg(id uint64) error {
+	return c.RevokeMsgCtx(c.ctx, id)
+}
+
+// RevokeMsgCtx 撤回消息 <消息 id（MsgSvrID）>
+func (c *Client) RevokeMsgCtx(ctx context.Context, id uint64) error {
+	if _, err := c.wxClient.RevokeMsgCtx(ctx, id); err != nil {
+		return fmt.Errorf("wxClient.RevokeMsg err: %w", err)
+	}
+	return nil
+}
+
+// findSentMsgId 在最新的消息库中查找自己发送的消息 <未找到时返回 0>
+// content 不为空时同时匹配消息内容
+func (c *Client) findSentMsgId(ctx context.Context, receiver string, msgType MsgType, since int64, content string) (uint64, error) {
+	db, err := c.latestMsgDB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	where := fmt.Sprintf("StrTalker = '%s' AND IsSender = 1 AND Type = %d AND CreateTime >= %d", receiver, msgType, since-1) // 容忍秒级的时间截断
+	if content != "" {
+		where += " AND StrContent = '" + strings.ReplaceAll(content, "'", "''") + "'"
+	}
+	sql := "SELECT MsgSvrID FROM MSG WHERE " + where + " ORDER BY localId DESC LIMIT 1;"
+	rows, err := c.wxClient.ExecDBQueryCtx(ctx, db, sql)
+	if err != nil {
+		return 0, fmt.Errorf("query sent msg err: %w", err)
+	}
+	if len(rows) == 0 || len(rows[0].GetFields()) == 0 {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(string(rows[0].GetFields()[0].GetContent()), 10, 64)
+	if err != nil {
+		return 0, nil // MsgSvrID 尚未回填
+	}
+	return id, nil
+}
+
+// latestMsgDB 编号最大的消息库名
+func (c *Client) latestMsgDB(ctx context.Context) (string, error) {
+	names, err := c.wxClient.GetDBNamesCtx(ctx)
+	if err != nil {
+		return "", fmt.Errorf("get db names err: %w", err)
+	}
+	latest, maxIdx := "", -1
+	for _, name := range names {
+		match := msgDBPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		if idx, _ := strconv.Atoi(match[1]); idx > maxIdx {
+			latest, maxIdx = name, idx
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("%w: no MSG db", ErrMsgNotFound)
+	}
+	return latest, nil
+}
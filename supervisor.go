@@ -0,0 +1,280 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午1:30:00
+// @Desc 连接状态监控与断线重连
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"github.com/Clov614/logging"
+	"github.com/Clov614/wcf-rpc-sdk/internal/wcf"
+	"sync"
+	"time"
+)
+
+// ConnState 与 wcf 的连接状态
+type ConnState int
+
+const (
+	StateUnknown      ConnState = iota // 尚未检查
+	StateConnected                     // 已连接且已登录
+	StateReconnecting                  // 连接异常，正在重连
+	StateLoggedOut                     // 连接正常，但微信未登录（或已退出登录）
+	StateClosed                        // 客户端已关闭
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateLoggedOut:
+		return "logged-out"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChange 连接状态变化
+type StateChange struct {
+	State ConnState `json:"state"`
+	Prev  ConnState `json:"prev"`
+	Err   error     `json:"-"` // 引起变化的错误（可能为 nil）
+	Ts    time.Time `json:"ts"`
+}
+
+// ReconnectPolicy 健康检查与重连策略
+type ReconnectPolicy struct {
+	HealthInterval time.Duration // 健康检查间隔
+	HealthTimeout  time.Duration // 单次健康检查超时
+	MaxFailures    int           // 连续失败多少次后重连
+	MinBackoff     time.Duration // 重连的初始等待时间
+	MaxBackoff     time.Duration // 重连的最大等待时间 <每次失败翻倍>
+}
+
+// DefaultReconnectPolicy 默认的重连策略
+var DefaultReconnectPolicy = ReconnectPolicy{
+	HealthInterval: 15 * time.Second,
+	HealthTimeout:  5 * time.Second,
+	MaxFailures:    2,
+	MinBackoff:     time.Second,
+	MaxBackoff:     time.Minute,
+}
+
+const stateChanSize = 16
+
+// connSupervisor 连接监控器的状态
+type connSupervisor struct {
+	policy  ReconnectPolicy
+	stateCh chan StateChange
+	onState func(StateChange)
+	state   ConnState
+	mu      sync.Mutex
+}
+
+func newConnSupervisor() *connSupervisor {
+	return &connSupervisor{
+		policy:  DefaultReconnectPolicy,
+		stateCh: make(chan StateChange, stateChanSize),
+	}
+}
+
+// SetReconnectPolicy 设置健康检查与重连策略 <需要在 Run 之前调用，零值字段使用默认值>
+func (c *Client) SetReconnectPolicy(p ReconnectPolicy) {
+	d := DefaultReconnectPolicy
+	if p.HealthInterval <= 0 {
+		p.HealthInterval = d.HealthInterval
+	}
+	if p.HealthTimeout <= 0 {
+		p.HealthTimeout = d.HealthTimeout
+	}
+	if p.MaxFailures <= 0 {
+		p.MaxFailures = d.MaxFailures
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = d.MinBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = p.MinBackoff
+	}
+	c.sv.mu.Lock()
+	c.sv.policy = p
+	c.sv.mu.Unlock()
+}
+
+// OnStateChange 设置连接状态变化的回调 <在监控协程中同步调用，请勿阻塞>
+func (c *Client) OnStateChange(fn func(StateChange)) {
+	c.sv.mu.Lock()
+	c.sv.onState = fn
+	c.sv.mu.Unlock()
+}
+
+// GetStateChan 返回连接状态变化的管道 <管道满时丢弃最新的变化>
+func (c *Client) GetStateChan() <-chan StateChange {
+	return c.sv.stateCh
+}
+
+// State 当前连接状态
+func (c *Client) State() ConnState {
+	c.sv.mu.Lock()
+	defer c.sv.mu.Unlock()
+	return c.sv.state
+}
+
+// setState 更新状态，状态未变化时不通知
+func (c *Client) setState(state ConnState, err error) {
+	c.sv.mu.Lock()
+	prev := c.sv.state
+	if prev == state {
+		c.sv.mu.Unlock()
+		return
+	}
+	c.sv.state = state
+	fn := c.sv.onState
+	c.sv.mu.Unlock()
+
+	change := StateChange{State: state, Prev: prev, Err: err, Ts: time.Now()}
+	logging.Info("wcf connection state changed", map[string]interface{}{"state": state.String(), "prev": prev.String(), "err": err})
+	select {
+	case c.sv.stateCh <- change:
+	default:
+		logging.Warn("state chan is full, drop state change", map[string]interface{}{"state": state.String()})
+	}
+	_ = c.publish(&LoginStateChanged{change})
+	if fn != nil {
+		fn(change)
+	}
+}
+
+func (c *Client) reconnectPolicy() ReconnectPolicy {
+	c.sv.mu.Lock()
+	defer c.sv.mu.Unlock()
+	return c.sv.policy
+}
+
+// supervise 监控连接：定时检查登录状态，命令或消息 socket 异常时重连并恢复消息接收
+func (c *Client) supervise(ctx context.Context, handler wcf.MsgHandler) {
+	policy := c.reconnectPolicy()
+	msgErr := make(chan error, 1)
+	loopCancel := c.startMsgLoop(ctx, handler, msgErr)
+	defer func() { loopCancel() }()
+
+	failures := 0
+	if err := c.checkHealth(ctx, policy.HealthTimeout); err != nil {
+		failures++
+	}
+	ticker := time.NewTicker(policy.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.setState(StateClosed, ctx.Err())
+			return
+		case err := <-msgErr:
+			if ctx.Err() != nil {
+				continue // 由 ctx.Done 分支退出
+			}
+			logging.ErrorWithErr(err, "message loop stopped, reconnecting")
+			loopCancel = c.reconnect(ctx, handler, msgErr, loopCancel, err)
+			failures = 0
+		case <-ticker.C:
+			err := c.checkHealth(ctx, policy.HealthTimeout)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			failures++
+			logging.WarnWithErr(err, "wcf health check failed", map[string]interface{}{"failures": failures})
+			if failures >= policy.MaxFailures {
+				loopCancel = c.reconnect(ctx, handler, msgErr, loopCancel, err)
+				failures = 0
+			}
+		}
+	}
+}
+
+// checkHealth 检查命令 socket 是否可用，并更新登录状态 <仅通信失败时返回错误>
+func (c *Client) checkHealth(ctx context.Context, timeout time.Duration) error {
+	ok, err := c.ping(ctx, timeout)
+	if err != nil {
+		return err
+	}
+	c.setLoginState(ok)
+	return nil
+}
+
+// ping 通过 IsLogin 检查命令 socket
+func (c *Client) ping(ctx context.Context, timeout time.Duration) (bool, error) {
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return c.wxClient.IsLoginCtx(hctx)
+}
+
+func (c *Client) setLoginState(isLogin bool) {
+	if isLogin {
+		c.setState(StateConnected, nil)
+	} else {
+		c.setState(StateLoggedOut, ErrNotLogin)
+	}
+}
+
+// startMsgLoop 开启消息接收，循环退出的错误写入 msgErr
+func (c *Client) startMsgLoop(ctx context.Context, handler wcf.MsgHandler, msgErr chan error) context.CancelFunc {
+	loopCtx, cancel := context.WithCancel(ctx)
+	if _, err := c.wxClient.EnableRecvTxtCtx(loopCtx); wcf.IsStatusError(err) { // 允许接收消息
+		// wcf 已在推送消息时（如重连后）返回非 0 状态，仍需开启本地接收
+		logging.WarnWithErr(err, "enable recv txt")
+		c.wxClient.RecvTxt.Store(true)
+	} else if err != nil {
+		select {
+		case msgErr <- err:
+		default:
+		}
+		return cancel
+	}
+	go func() {
+		err := c.wxClient.OnMSG(loopCtx, handler) // 当消息到来时，处理消息
+		if loopCtx.Err() != nil {
+			return // 主动停止（退出或重连）
+		}
+		if err == nil {
+			err = wcf.ErrClosed
+		}
+		msgErr <- err
+	}()
+	return cancel
+}
+
+// reconnect 退避重连直到成功或 ctx 结束，成功后重新开启消息接收
+func (c *Client) reconnect(ctx context.Context, handler wcf.MsgHandler, msgErr chan error, loopCancel context.CancelFunc, cause error) context.CancelFunc {
+	c.setState(StateReconnecting, cause)
+	loopCancel()
+	policy := c.reconnectPolicy()
+	backoff := policy.MinBackoff
+	var isLogin bool
+	for {
+		err := c.wxClient.Reconnect()
+		if err == nil {
+			isLogin, err = c.ping(ctx, policy.HealthTimeout)
+		}
+		if err == nil {
+			break
+		}
+		logging.WarnWithErr(err, "wcf reconnect failed", map[string]interface{}{"backoff": backoff.String()})
+		select {
+		case <-ctx.Done():
+			return loopCancel
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+	loopCancel = c.startMsgLoop(ctx, handler, msgErr)
+	c.setLoginState(isLogin)
+	return loopCancel
+}
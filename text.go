@@ -0,0 +1,204 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午11:30:00
+// @Desc 文本的 @ 占位符与超长文本切分
+package wcf_rpc_sdk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	DefaultMaxTextLen = 2000         // 单条文本的默认最大长度（字符）
+	minTextLen        = 20           // 切分时单条文本的最小长度
+	atAll             = "notify@all" // 艾特所有人
+	atSeparator       = "\u2005"     // 微信在 @昵称 后插入的分隔符
+	partSuffixFormat  = "\n(%d/%d)"  // 切分后每段末尾的编号
+	partSuffixReserve = 10           // 为编号预留的长度 <"\n(999/999)">
+)
+
+// mentionPattern @ 占位符 <{at:wxid}，{at:notify@all} 为所有人>
+var mentionPattern = regexp.MustCompile(`\{at:([^{}\s]+)\}`)
+
+// textSpan 文本中的一段 <rune 下标，[start, end)>
+type textSpan struct {
+	start, end int
+}
+
+// renderMentions 替换 {at:wxid} 占位符，并将 ats 中其余的成员添加到开头 <内容中其他的 @ 保持原样>
+// 返回替换后的文本、艾特列表以及 @昵称 在文本中的位置
+func (c *Client) renderMentions(receiver string, content string, ats []string) (string, []string, []textSpan, error) {
+	var atList []string
+	seen := make(map[string]bool)
+	mention := func(wxid string) (string, error) { // 返回 @昵称 文本 <群聊优先使用群昵称>
+		name := "所有人"
+		if wxid != atAll {
+			var err error
+			if name, err = c.memberName(receiver, wxid); err != nil {
+				return "", err
+			}
+		}
+		if !seen[wxid] {
+			seen[wxid] = true
+			atList = append(atList, wxid)
+		}
+		return "@" + name + atSeparator, nil
+	}
+
+	var b strings.Builder
+	var spans []textSpan
+	var runes int
+	write := func(s string, isMention bool) {
+		n := len([]rune(s))
+		if isMention {
+			spans = append(spans, textSpan{runes, runes + n})
+		}
+		b.WriteString(s)
+		runes += n
+	}
+
+	for _, wxid := range ats {
+		if strings.Contains(content, "{at:"+wxid+"}") {
+			continue
+		}
+		text, err := mention(wxid)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		write(text, true)
+	}
+	last := 0
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		text, err := mention(content[loc[2]:loc[3]])
+		if err != nil {
+			return "", nil, nil, err
+		}
+		write(content[last:loc[0]], false)
+		write(text, true)
+		last = loc[1]
+	}
+	write(content[last:], false)
+	return b.String(), atList, spans, nil
+}
+
+// splitText 将超长文本切分为不超过 limit 个字符的多段 <依次尝试在段落、句子、空白处切分，尽量不拆开 @昵称>
+// 单个 @昵称 超过 limit 时只能在 limit 处硬切
+func splitText(text string, limit int, keep []textSpan) []string {
+	runes := []rune(text)
+	var parts []string
+	add := func(start, end int) {
+		if part := strings.TrimRightFunc(string(runes[start:end]), unicode.IsSpace); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	start := 0
+	for len(runes)-start > limit {
+		cut := start + cutPoint(runes[start:], limit)
+		for _, span := range keep {
+			if span.start < cut && cut < span.end {
+				switch {
+				case span.start > start:
+					cut = span.start
+				case span.end-start <= limit:
+					cut = span.end
+				default:
+					cut = start + limit
+				}
+				break
+			}
+		}
+		add(start, cut)
+		for start = cut; start < len(runes) && unicode.IsSpace(runes[start]); start++ {
+		}
+	}
+	add(start, len(runes))
+	return parts
+}
+
+// cutPoint 在 runes 的前 limit 个字符中选择切分位置 <返回切分后前一段的长度>
+func cutPoint(runes []rune, limit int) int {
+	window := runes[:limit]
+	floor := limit / 3 // 切分点太靠前时放弃该级别，避免产生过短的段
+	boundaries := []func(i int) bool{
+		func(i int) bool { return window[i] == '\n' && i > 0 && window[i-1] == '\n' }, // 段落
+		func(i int) bool { return window[i] == '\n' },                                 // 换行
+		func(i int) bool { return strings.ContainsRune("。！？!?；;…", window[i]) },       // 句子
+		func(i int) bool { // 英文句号后需跟空白，避免拆开小数、网址
+			return window[i] == '.' && (i+1 == len(runes) || unicode.IsSpace(runes[i+1]))
+		},
+		func(i int) bool { return unicode.IsSpace(window[i]) || window[i] == '，' || window[i] == ',' },
+	}
+	for _, isBoundary := range boundaries {
+		for i := len(window) - 1; i >= floor; i-- {
+			if isBoundary(i) {
+				return i + 1
+			}
+		}
+	}
+	return limit
+}
+
+// SetMaxTextLen 设置 SendLongText 单条文本的最大长度（字符） <n <= 0 时恢复 DefaultMaxTextLen>
+func (c *Client) SetMaxTextLen(n int) {
+	if n <= 0 {
+		n = DefaultMaxTextLen
+	}
+	c.textLimit.Store(int64(max(n, minTextLen)))
+}
+
+func (c *Client) maxTextLen() int {
+	if n := c.textLimit.Load(); n > 0 {
+		return int(n)
+	}
+	return DefaultMaxTextLen
+}
+
+// SendLongText 发送文本，超长时在段落或句子处切分为多条并编号 <参数同 SendText> <艾特只随第一条发出>
+func (c *Client) SendLongText(receiver string, content string, ats ...string) ([]*SendResult, error) {
+	futures := c.Outbox(SendPriorityNormal).LongText(receiver, content, ats...)
+	return waitAll(futures)
+}
+
+// LongText 排队发送可能超长的文本 <参数同 Client.SendLongText>
+func (o Outbox) LongText(receiver string, content string, ats ...string) []*SendFuture {
+	content, atList, spans, err := o.cli.renderMentions(receiver, content, ats)
+	if err != nil {
+		return []*SendFuture{failedSend(err)}
+	}
+	limit := o.cli.maxTextLen()
+	if len([]rune(content)) <= limit {
+		return []*SendFuture{o.text(receiver, content, atList)}
+	}
+	parts := splitText(content, limit-partSuffixReserve, spans)
+	futures := make([]*SendFuture, len(parts))
+	for i, part := range parts { // 同一会话按排队顺序发送，各段不会乱序
+		part += fmt.Sprintf(partSuffixFormat, i+1, len(parts))
+		if i == 0 {
+			futures[i] = o.text(receiver, part, atList)
+		} else {
+			futures[i] = o.text(receiver, part, nil)
+		}
+	}
+	return futures
+}
+
+// waitAll 依次等待，返回第一个错误
+func waitAll(futures []*SendFuture) ([]*SendResult, error) {
+	results := make([]*SendResult, 0, len(futures))
+	for _, f := range futures {
+		res, err := f.Wait()
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// ReplyLongText 回复文本，超长时切分为多条
+func (m *Message) ReplyLongText(content string, ats ...string) ([]*SendResult, error) {
+	return m.meta.ReplyLongText(content, ats...)
+}
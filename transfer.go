@@ -0,0 +1,146 @@
+// Package wcf_rpc_sdk
+// @Author Clover
+// @Data 2026/10/18 下午7:00:00
+// @Desc 转账消息解析与收款
+package wcf_rpc_sdk
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotTransfer         = errors.New("not a transfer message")
+	ErrTransferNotPending  = errors.New("transfer is not waiting to be received")
+	ErrTransferFromSelf    = errors.New("can not receive a transfer sent by self")
+	ErrTransferMissingInfo = errors.New("transfer id or transaction id missing")
+)
+
+// TransferState 转账状态 <wcpayinfo 中的 paysubtype>
+type TransferState int
+
+const (
+	TransferUnknown  TransferState = 0
+	TransferSent     TransferState = 1 // 已发送，待收款
+	TransferReceived TransferState = 3 // 已收款
+	TransferRefunded TransferState = 4 // 已退还
+)
+
+var transferStateNames = map[TransferState]string{
+	TransferSent:     "sent",
+	TransferReceived: "received",
+	TransferRefunded: "refunded",
+}
+
+func (s TransferState) String() string {
+	if name, ok := transferStateNames[s]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Transfer 转账消息
+type Transfer struct {
+	Amount        float64       `json:"amount"`               // 金额（元）
+	FeeDesc       string        `json:"feeDesc"`              // 金额描述，如 ￥0.01
+	Memo          string        `json:"memo,omitempty"`       // 转账说明
+	Payer         string        `json:"payer"`                // 付款人 wxid
+	Receiver      string        `json:"receiver"`             // 收款人 wxid
+	State         TransferState `json:"state"`                // 转账状态
+	TransferId    string        `json:"transferId"`           // transferid
+	TransactionId string        `json:"transactionId"`        // transcationid
+	BeginTime     time.Time     `json:"beginTime,omitempty"`  // 发起时间
+	ExpireTime    time.Time     `json:"expireTime,omitempty"` // 过期时间，过期未收款将退还
+}
+
+// IsExpired 是否已过期
+func (t *Transfer) IsExpired() bool {
+	return !t.ExpireTime.IsZero() && time.Now().After(t.ExpireTime)
+}
+
+type transferXML struct {
+	Type    AppMsgType `xml:"appmsg>type"`
+	PayInfo struct {
+		PaySubType    int    `xml:"paysubtype"`
+		FeeDesc       string `xml:"feedesc"`
+		TransactionId string `xml:"transcationid"` // 微信的拼写
+		TransferId    string `xml:"transferid"`
+		InvalidTime   int64  `xml:"invalidtime"`
+		BeginTime     int64  `xml:"begintransfertime"`
+		PayMemo       string `xml:"pay_memo"`
+		Receiver      string `xml:"receiver_username"`
+		Payer         string `xml:"payer_username"`
+	} `xml:"appmsg>wcpayinfo"`
+}
+
+// parseTransfer 解析转账消息 <appmsg type 2000 的 wcpayinfo>
+func parseTransfer(content string) (*Transfer, error) {
+	var msg transferXML
+	if err := xml.Unmarshal([]byte(content), &msg); err != nil {
+		return nil, fmt.Errorf("unmarshal transfer err: %w", err)
+	}
+	if msg.Type != AppMsgTypeTransfer {
+		return nil, ErrNotTransfer
+	}
+	p := msg.PayInfo
+	t := &Transfer{
+		FeeDesc:       p.FeeDesc,
+		Memo:          p.PayMemo,
+		Payer:         p.Payer,
+		Receiver:      p.Receiver,
+		State:         TransferState(p.PaySubType),
+		TransferId:    p.TransferId,
+		TransactionId: p.TransactionId,
+	}
+	amount := strings.TrimLeft(strings.TrimSpace(p.FeeDesc), "￥¥")
+	if v, err := strconv.ParseFloat(amount, 64); err == nil {
+		t.Amount = v
+	}
+	if p.BeginTime > 0 {
+		t.BeginTime = time.Unix(p.BeginTime, 0)
+	}
+	if p.InvalidTime > 0 {
+		t.ExpireTime = time.Unix(p.InvalidTime, 0)
+	}
+	return t, nil
+}
+
+// ReceiveTransfer 收款 <付款人 wxid> <transferid> <transcationid>
+func (c *Client) ReceiveTransfer(payer string, transferId string, transactionId string) error {
+	return c.ReceiveTransferCtx(c.ctx, payer, transferId, transactionId)
+}
+
+// ReceiveTransferCtx 收款 <付款人 wxid> <transferid> <transcationid>
+func (c *Client) ReceiveTransferCtx(ctx context.Context, payer string, transferId string, transactionId string) error {
+	if _, err := c.wxClient.ReceiveTransferCtx(ctx, payer, transferId, transactionId); err != nil {
+		return fmt.Errorf("wxClient.ReceiveTransfer err: %w", err)
+	}
+	return nil
+}
+
+// AcceptTransfer 收下该转账 <仅对别人发来的、待收款的转账有效>
+func (m *Message) AcceptTransfer() error {
+	t := m.Transfer
+	if t == nil {
+		return ErrNotTransfer
+	}
+	if m.IsSelf {
+		return ErrTransferFromSelf
+	}
+	if t.State != TransferSent {
+		return fmt.Errorf("%w: state %s", ErrTransferNotPending, t.State)
+	}
+	if t.TransferId == "" || t.TransactionId == "" {
+		return ErrTransferMissingInfo
+	}
+	payer := t.Payer
+	if payer == "" {
+		payer = m.WxId
+	}
+	return m.meta.ReceiveTransfer(payer, t.TransferId, t.TransactionId)
+}